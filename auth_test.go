@@ -2,6 +2,8 @@
 package main
 
 import (
+	"crypto/ed25519"
+	"crypto/rand"
 	"fmt"
 	"net/http"
 	"strings"
@@ -10,7 +12,9 @@ import (
 
 	"github.com/jpheneger/chirpy/internal/auth"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
 )
 
 func TestMakeJWT(t *testing.T) {
@@ -53,4 +57,432 @@ func TestGetBearerToken(t *testing.T) {
 	} else if token != expected {
 		t.Errorf("expected %s, got %s", expected, token)
 	}
+
+	for _, authorization := range []string{"", "Bearer", "Bearer ", "Basic abc", "ApiKey " + expected} {
+		headers.Set("Authorization", authorization)
+		if token, err := auth.GetBearerToken(headers); err == nil {
+			t.Errorf("GetBearerToken(%q) = %q; want an error", authorization, token)
+		}
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldRing, err := auth.ParseKeyring("old:oldsecret")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	userId := uuid.New()
+	token, err := oldRing.MakeJWT(userId, auth.Access{}, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed with error: %v", err)
+	}
+
+	rotated, err := auth.ParseKeyring("new:newsecret,old:oldsecret")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	got, err := rotated.ValidateJWT(token)
+	if err != nil {
+		t.Errorf("token signed with previous key rejected after rotation: %v", err)
+	} else if got != userId {
+		t.Errorf("expected %s, got %s", userId, got)
+	}
+
+	retired, err := auth.ParseKeyring("new:newsecret,old:oldsecret@2000-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	if _, err := retired.ValidateJWT(token); err == nil {
+		t.Errorf("token signed with retired key was accepted")
+	}
+
+	unknown, err := auth.ParseKeyring("other:othersecret")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	if _, err := unknown.ValidateJWT(token); err == nil {
+		t.Errorf("token signed with unknown key was accepted")
+	}
+}
+
+func TestSecretSigningKey(t *testing.T) {
+	userId := uuid.New()
+	for _, secret := range []string{"p@ss:word", "one,two", "k1:secret@2000-01-01T00:00:00Z"} {
+		ring, err := auth.NewKeyring(auth.SecretSigningKey(secret))
+		if err != nil {
+			t.Fatalf("NewKeyring(%q) failed with error: %v", secret, err)
+		}
+		token, err := ring.MakeJWT(userId, auth.Access{}, 5*time.Minute)
+		if err != nil {
+			t.Fatalf("MakeJWT failed with error: %v", err)
+		}
+
+		// The whole secret is the HMAC key.
+		_, err = jwt.Parse(token, func(*jwt.Token) (interface{}, error) { return []byte(secret), nil })
+		if err != nil {
+			t.Errorf("token from %q isn't signed with the literal secret: %v", secret, err)
+		}
+
+		// Moving to SIGNING_KEYS keeps the plain secret's tokens valid.
+		rotated, err := auth.NewKeyring(auth.SigningKey{ID: "k2", Secret: []byte("newsecret")}, auth.SecretSigningKey(secret))
+		if err != nil {
+			t.Fatalf("NewKeyring failed with error: %v", err)
+		}
+		if got, err := rotated.ValidateJWT(token); err != nil || got != userId {
+			t.Errorf("token from %q rejected after rotation: %v", secret, err)
+		}
+	}
+}
+
+func TestValidateJWTWrongSecret(t *testing.T) {
+	result, err := auth.MakeJWT(uuid.New(), "test", 5*time.Minute)
+	if err != nil {
+		t.Errorf("MakeJWT failed with error: %v", err)
+	}
+	if _, err := auth.ValidateJWT(result, "not-the-secret"); err == nil {
+		t.Errorf("token validated with the wrong secret")
+	}
+}
+
+func TestKeyringEd25519AndJWKS(t *testing.T) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate key: %v", err)
+	}
+	keyring, err := auth.NewKeyring(
+		auth.SigningKey{ID: "ed1", Private: private},
+		auth.SigningKey{ID: "hs1", Secret: []byte("secret")},
+	)
+	if err != nil {
+		t.Fatalf("unable to create keyring: %v", err)
+	}
+
+	userId := uuid.New()
+	token, err := keyring.MakeJWT(userId, auth.Access{}, 5*time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed with error: %v", err)
+	}
+	if got, err := keyring.ValidateJWT(token); err != nil || got != userId {
+		t.Errorf("ValidateJWT = %s, %v; want %s", got, err, userId)
+	}
+
+	jwks := keyring.JWKS()
+	if len(jwks.Keys) != 1 {
+		t.Fatalf("expected only the public key in the JWKS, got %d keys", len(jwks.Keys))
+	}
+	if jwks.Keys[0].Kid != "ed1" || jwks.Keys[0].Kty != "OKP" || jwks.Keys[0].Alg != "EdDSA" {
+		t.Errorf("unexpected JWK: %+v", jwks.Keys[0])
+	}
+}
+
+func TestValidateJWTClaims(t *testing.T) {
+	now := time.Now()
+	valid := auth.MyCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    auth.TokenIssuer,
+			Audience:  jwt.ClaimStrings{auth.TokenAudience},
+			Subject:   uuid.NewString(),
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+			NotBefore: jwt.NewNumericDate(now.Add(-time.Minute)),
+		},
+		TokenType: auth.AccessTokenType,
+	}
+	cases := map[string]func(c *auth.MyCustomClaims){
+		"valid":          func(c *auth.MyCustomClaims) {},
+		"wrong issuer":   func(c *auth.MyCustomClaims) { c.Issuer = "someone-else" },
+		"wrong audience": func(c *auth.MyCustomClaims) { c.Audience = jwt.ClaimStrings{"someone-else"} },
+		"not yet valid":  func(c *auth.MyCustomClaims) { c.NotBefore = jwt.NewNumericDate(now.Add(time.Hour)) },
+		"missing nbf":    func(c *auth.MyCustomClaims) { c.NotBefore = nil },
+		"missing type":   func(c *auth.MyCustomClaims) { c.TokenType = "" },
+		"wrong type":     func(c *auth.MyCustomClaims) { c.TokenType = "refresh" },
+	}
+	for name, mutate := range cases {
+		claims := valid
+		mutate(&claims)
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("test"))
+		if err != nil {
+			t.Fatalf("%s: unable to sign token: %v", name, err)
+		}
+		_, err = auth.ValidateJWT(token, "test")
+		if name == "valid" && err != nil {
+			t.Errorf("%s: unexpected error: %v", name, err)
+		} else if name != "valid" && err == nil {
+			t.Errorf("%s: token was accepted", name)
+		}
+	}
+}
+
+func TestHashToken(t *testing.T) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		t.Fatalf("MakeRefreshToken failed with error: %v", err)
+	}
+	hash := auth.HashToken([]byte("key"), token)
+	if hash == token {
+		t.Errorf("HashToken returned the token unchanged")
+	}
+	if hash != auth.HashToken([]byte("key"), token) {
+		t.Errorf("HashToken is not deterministic")
+	}
+	if hash == auth.HashToken([]byte("other-key"), token) {
+		t.Errorf("HashToken ignores its key")
+	}
+	if hash == auth.LegacyTokenHash(token) {
+		t.Errorf("keyed hash matches the legacy unkeyed hash")
+	}
+}
+
+func TestEmailVerificationToken(t *testing.T) {
+	keyring, err := auth.ParseKeyring("k1:secret")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	userId := uuid.New()
+	token, err := keyring.MakeEmailVerificationToken(userId, "me@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("MakeEmailVerificationToken failed with error: %v", err)
+	}
+
+	gotUser, gotEmail, err := keyring.ValidateEmailVerificationToken(token)
+	if err != nil {
+		t.Fatalf("unable to validate verification token: %v", err)
+	}
+	if gotUser != userId || gotEmail != "me@example.com" {
+		t.Errorf("got %s %s, want %s me@example.com", gotUser, gotEmail, userId)
+	}
+
+	if _, err := keyring.ValidateJWT(token); err == nil {
+		t.Errorf("verification token was accepted as an access token")
+	}
+	accessToken, err := keyring.MakeJWT(userId, auth.Access{}, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed with error: %v", err)
+	}
+	if _, _, err := keyring.ValidateEmailVerificationToken(accessToken); err == nil {
+		t.Errorf("access token was accepted as a verification token")
+	}
+}
+
+func TestMagicLinkToken(t *testing.T) {
+	keyring, err := auth.ParseKeyring("k1:secret")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	token, err := keyring.MakeMagicLinkToken("me@example.com", 15*time.Minute)
+	if err != nil {
+		t.Fatalf("MakeMagicLinkToken failed with error: %v", err)
+	}
+
+	tokenId, email, expiresAt, err := keyring.ValidateMagicLinkToken(token)
+	if err != nil {
+		t.Fatalf("unable to validate magic link token: %v", err)
+	}
+	if tokenId == uuid.Nil || email != "me@example.com" {
+		t.Errorf("got %s %s, want a token id and me@example.com", tokenId, email)
+	}
+	if until := time.Until(expiresAt); until <= 14*time.Minute || until > 15*time.Minute {
+		t.Errorf("token expires in %s, want 15m", until)
+	}
+
+	other, _ := keyring.MakeMagicLinkToken("me@example.com", 15*time.Minute)
+	if otherId, _, _, _ := keyring.ValidateMagicLinkToken(other); otherId == tokenId {
+		t.Errorf("two magic links share the id %s", tokenId)
+	}
+	if _, err := keyring.ValidateJWT(token); err == nil {
+		t.Errorf("magic link token was accepted as an access token")
+	}
+	verification, _ := keyring.MakeEmailVerificationToken(uuid.New(), "me@example.com", time.Hour)
+	if _, _, _, err := keyring.ValidateMagicLinkToken(verification); err == nil {
+		t.Errorf("verification token was accepted as a magic link token")
+	}
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 appendix B SHA1 vectors, truncated to six digits.
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	vectors := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, expected := range vectors {
+		code, err := auth.TOTPCode(secret, time.Unix(unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode failed with error: %v", err)
+		}
+		if code != expected {
+			t.Errorf("TOTPCode at %d = %s; want %s", unix, code, expected)
+		}
+	}
+
+	now := time.Unix(1111111109, 0)
+	if _, ok := auth.ValidateTOTP(secret, "081804", now.Add(30*time.Second)); !ok {
+		t.Errorf("code from the previous period was rejected")
+	}
+	if _, ok := auth.ValidateTOTP(secret, "081804", now.Add(5*time.Minute)); ok {
+		t.Errorf("stale code was accepted")
+	}
+}
+
+func TestEncryptSecret(t *testing.T) {
+	key := auth.EncryptionKey("passphrase")
+	owner := uuid.New()
+	sealed, err := auth.EncryptSecret(key, "JBSWY3DPEHPK3PXP", owner[:])
+	if err != nil {
+		t.Fatalf("EncryptSecret failed with error: %v", err)
+	}
+	if strings.Contains(sealed, "JBSWY3DPEHPK3PXP") {
+		t.Errorf("ciphertext contains the plaintext")
+	}
+
+	opened, err := auth.DecryptSecret(key, sealed, owner[:])
+	if err != nil || opened != "JBSWY3DPEHPK3PXP" {
+		t.Errorf("DecryptSecret = %q, %v", opened, err)
+	}
+	other := uuid.New()
+	if _, err := auth.DecryptSecret(key, sealed, other[:]); err == nil {
+		t.Errorf("ciphertext opened for a different owner")
+	}
+}
+
+func TestArgon2idHasher(t *testing.T) {
+	params := auth.Argon2idParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+	hasher := auth.NewArgon2idHasher(params)
+
+	hash, err := hasher.Hash("correct horse battery staple")
+	if err != nil {
+		t.Fatalf("Hash failed with error: %v", err)
+	}
+	if !strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$") {
+		t.Errorf("unexpected hash format: %s", hash)
+	}
+	if ok, err := hasher.Verify(hash, "correct horse battery staple"); !ok || err != nil {
+		t.Errorf("Verify = %v, %v; want true", ok, err)
+	}
+	if ok, _ := hasher.Verify(hash, "wrong"); ok {
+		t.Errorf("wrong password verified")
+	}
+	if hasher.NeedsRehash(hash) {
+		t.Errorf("fresh hash needs rehash")
+	}
+
+	stronger := params
+	stronger.Iterations = 2
+	if !auth.NewArgon2idHasher(stronger).NeedsRehash(hash) {
+		t.Errorf("hash with outdated parameters doesn't need rehash")
+	}
+}
+
+func TestArgon2idHasherVerifiesBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("unable to generate bcrypt hash: %v", err)
+	}
+	hasher := auth.NewArgon2idHasher(auth.DefaultArgon2idParams)
+	if ok, err := hasher.Verify(string(legacy), "hunter2"); !ok || err != nil {
+		t.Errorf("Verify = %v, %v; want true", ok, err)
+	}
+	if ok, _ := hasher.Verify(string(legacy), "hunter3"); ok {
+		t.Errorf("wrong password verified against bcrypt hash")
+	}
+	if !hasher.NeedsRehash(string(legacy)) {
+		t.Errorf("bcrypt hash doesn't need rehash")
+	}
+}
+
+func TestLockoutPolicy(t *testing.T) {
+	policy := auth.LockoutPolicy{Threshold: 5, Base: 30 * time.Second, Max: 5 * time.Minute}
+	expected := map[int]time.Duration{
+		1:  0,
+		5:  0,
+		6:  30 * time.Second,
+		7:  time.Minute,
+		8:  2 * time.Minute,
+		9:  4 * time.Minute,
+		10: 5 * time.Minute,
+		50: 5 * time.Minute,
+	}
+	for failures, want := range expected {
+		if got := policy.Lockout(failures); got != want {
+			t.Errorf("Lockout(%d) = %s; want %s", failures, got, want)
+		}
+	}
+}
+
+func TestPersonalAccessToken(t *testing.T) {
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken: %v", err)
+	}
+	if !auth.IsPersonalAccessToken(token) {
+		t.Errorf("%q not recognized as a personal access token", token)
+	}
+	refresh, _ := auth.MakeRefreshToken()
+	if auth.IsPersonalAccessToken(refresh) {
+		t.Errorf("refresh token recognized as a personal access token")
+	}
+
+	if !auth.ValidScope(auth.ScopeChirpsWrite) || auth.ValidScope("chirps:admin") {
+		t.Errorf("ValidScope accepted the wrong scopes")
+	}
+	granted := []string{auth.ScopeChirpsRead}
+	if !auth.HasScope(granted, auth.ScopeChirpsRead) || auth.HasScope(granted, auth.ScopeChirpsWrite) {
+		t.Errorf("HasScope(%v) returned the wrong result", granted)
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	// Example from RFC 7636 appendix B.
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	if !auth.VerifyPKCE(verifier, challenge) {
+		t.Errorf("VerifyPKCE rejected the RFC 7636 example")
+	}
+	if auth.VerifyPKCE(verifier[1:]+"x", challenge) {
+		t.Errorf("VerifyPKCE accepted the wrong verifier")
+	}
+	if auth.VerifyPKCE("short", challenge) {
+		t.Errorf("VerifyPKCE accepted a verifier under 43 characters")
+	}
+}
+
+func TestParseScope(t *testing.T) {
+	scopes, err := auth.ParseScope("chirps:write  chirps:read chirps:write")
+	if err != nil {
+		t.Fatalf("ParseScope: %v", err)
+	}
+	if len(scopes) != 2 || scopes[0] != auth.ScopeChirpsWrite || scopes[1] != auth.ScopeChirpsRead {
+		t.Errorf("ParseScope = %v", scopes)
+	}
+	if _, err := auth.ParseScope("chirps:read admin"); err == nil {
+		t.Errorf("ParseScope accepted an unknown scope")
+	}
+}
+
+func TestAccessClaims(t *testing.T) {
+	keyring, err := auth.ParseKeyring("k1:secret")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	userId := uuid.New()
+	access := auth.Access{
+		Roles:       []string{auth.RoleUser, auth.RoleModerator},
+		Permissions: []string{auth.PermissionChirpsModerate},
+	}
+	token, err := keyring.MakeJWT(userId, access, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed with error: %v", err)
+	}
+
+	gotUser, gotAccess, err := keyring.ValidateAccessToken(token)
+	if err != nil {
+		t.Fatalf("ValidateAccessToken failed with error: %v", err)
+	}
+	if gotUser != userId || len(gotAccess.Roles) != 2 || gotAccess.Roles[1] != auth.RoleModerator {
+		t.Errorf("got %s %+v, want %s %+v", gotUser, gotAccess, userId, access)
+	}
+	if !gotAccess.HasPermission(auth.PermissionChirpsModerate) || gotAccess.HasPermission(auth.PermissionSystemReset) {
+		t.Errorf("HasPermission on %v returned the wrong result", gotAccess.Permissions)
+	}
 }
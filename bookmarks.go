@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+type BookmarkedChirp struct {
+	Chirp
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+// handlerBookmarkChirp saves a chirp, or the chirp a rechirp reposts, to the
+// caller's bookmarks. Bookmarking a chirp twice has no further effect.
+func (cfg *apiConfig) handlerBookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	chirp, err := cfg.originalChirp(chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+
+	err = cfg.db.BookmarkChirp(context.Background(), database.BookmarkChirpParams{
+		UserID:  principalFrom(r.Context()).UserID,
+		ChirpID: chirp.ID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to bookmark chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerDeleteBookmark removes a bookmark, succeeding whether or not there
+// was one.
+func (cfg *apiConfig) handlerDeleteBookmark(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	if chirp, err := cfg.originalChirp(chirpId); err == nil {
+		chirpId = chirp.ID
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+
+	err = cfg.db.DeleteBookmark(context.Background(), database.DeleteBookmarkParams{
+		UserID:  principalFrom(r.Context()).UserID,
+		ChirpID: chirpId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete bookmark", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerListBookmarks lists the caller's bookmarks, most recent first. Only
+// the owner can see them.
+func (cfg *apiConfig) handlerListBookmarks(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	limit, err := parsePageSize(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params := database.ListBookmarkedChirpsParams{
+		UserID:     principalFrom(r.Context()).UserID,
+		MaxResults: int32(limit + 1),
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := parsePageCursor(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	rows, err := cfg.db.ListBookmarkedChirps(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get bookmarks from db", err)
+		return
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		cfg.setPageLinks(w, r, pageCursor{CreatedAt: last.BookmarkedAt, ID: last.ID}.String(), "")
+	}
+
+	chirps := []Chirp{}
+	for _, row := range rows {
+		chirps = append(chirps, chirpFromDB(database.Chirp{
+			ID:            row.ID,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     row.UpdatedAt,
+			Body:          row.Body,
+			UserID:        row.UserID,
+			RevisionCount: row.RevisionCount,
+			ReplyToID:     row.ReplyToID,
+			ReplyCount:    row.ReplyCount,
+			RechirpOfID:   row.RechirpOfID,
+			QuoteOfID:     row.QuoteOfID,
+			LikeCount:     row.LikeCount,
+		}))
+	}
+	if err := cfg.decorateChirps(r, chirps); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details from db", err)
+		return
+	}
+
+	responseBody := []BookmarkedChirp{}
+	for i, chirp := range chirps {
+		responseBody = append(responseBody, BookmarkedChirp{Chirp: chirp, BookmarkedAt: rows[i].BookmarkedAt})
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+func bookmarkRows(chirps ...database.Chirp) *sqlmock.Rows {
+	rows := sqlmock.NewRows(append(chirpColumns, "bookmarked_at"))
+	for i, chirp := range chirps {
+		rows.AddRow(append(chirpValues(chirp), driver.Value(time.Now().Add(-time.Duration(i)*time.Minute)))...)
+	}
+	return rows
+}
+
+func TestListBookmarksPages(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	userId := uuid.New()
+	first := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "first", UserID: uuid.New()}
+	second := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "second", UserID: uuid.New()}
+
+	// Only the caller's own bookmarks are listed, one more than the page
+	// size to tell whether there is a next page.
+	mock.ExpectQuery("ListBookmarkedChirps").WithArgs(userId, nil, nil, 2).WillReturnRows(bookmarkRows(first, second))
+	mock.ExpectQuery("GetLikedChirpIds").WithArgs(userId, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}))
+	rec := httptest.NewRecorder()
+	cfg.handlerListBookmarks(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/bookmarks?limit=1", nil), userId))
+
+	var got []BookmarkedChirp
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if len(got) != 1 || got[0].Id != first.ID {
+		t.Fatalf("got %+v, want only the first bookmark", got)
+	}
+	link := rec.Header().Get("Link")
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start || !strings.HasSuffix(link, `rel="next"`) {
+		t.Fatalf("Link = %q, want a next page", link)
+	}
+	next, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := parsePageCursor(next.Query().Get("cursor"))
+	if err != nil || cursor.ID != first.ID || !cursor.CreatedAt.Equal(got[0].BookmarkedAt) {
+		t.Fatalf("next cursor = %+v, %v, want the first bookmark", cursor, err)
+	}
+
+	// Following the link resumes after the first bookmark.
+	mock.ExpectQuery("ListBookmarkedChirps").WithArgs(userId, sqlmock.AnyArg(), cursor.ID, 2).WillReturnRows(bookmarkRows(second))
+	mock.ExpectQuery("GetLikedChirpIds").WithArgs(userId, sqlmock.AnyArg()).WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}))
+	rec = httptest.NewRecorder()
+	cfg.handlerListBookmarks(rec, asUser(httptest.NewRequest(http.MethodGet, next.RequestURI(), nil), userId))
+	if rec.Code != http.StatusOK || rec.Header().Get("Link") != "" {
+		t.Errorf("got %d with Link %q, want the last page", rec.Code, rec.Header().Get("Link"))
+	}
+}
+
+func TestListBookmarksRejectsBadCursor(t *testing.T) {
+	cfg, _, _ := newMockConfig(t)
+	rec := httptest.NewRecorder()
+	cfg.handlerListBookmarks(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/bookmarks?cursor=nope", nil), uuid.New()))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("got %d, want 400", rec.Code)
+	}
+}
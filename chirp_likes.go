@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+type ChirpLike struct {
+	UserId  uuid.UUID `json:"user_id"`
+	LikedAt time.Time `json:"liked_at"`
+}
+
+// handlerLikeChirp likes a chirp, or the chirp a rechirp reposts. Liking a
+// chirp twice has no further effect.
+func (cfg *apiConfig) handlerLikeChirp(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	chirp, err := cfg.originalChirp(chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+
+	err = cfg.db.LikeChirp(context.Background(), database.LikeChirpParams{
+		ChirpID: chirp.ID,
+		UserID:  principalFrom(r.Context()).UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to like chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerUnlikeChirp removes a like, succeeding whether or not there was one.
+func (cfg *apiConfig) handlerUnlikeChirp(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	// A deleted chirp can still be unliked by its own id.
+	if chirp, err := cfg.originalChirp(chirpId); err == nil {
+		chirpId = chirp.ID
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+
+	err = cfg.db.UnlikeChirp(context.Background(), database.UnlikeChirpParams{
+		ChirpID: chirpId,
+		UserID:  principalFrom(r.Context()).UserID,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to unlike chirp", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlerChirpLikes lists who liked a chirp, or the chirp a rechirp reposts,
+// in the order they liked it.
+func (cfg *apiConfig) handlerChirpLikes(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	query := r.URL.Query()
+	limit, err := parsePageSize(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params := database.ListChirpLikesParams{MaxResults: int32(limit + 1)}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := parsePageCursor(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	chirp, err := cfg.originalChirp(chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+	params.ChirpID = chirp.ID
+
+	likes, err := cfg.db.ListChirpLikes(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get likes from db", err)
+		return
+	}
+	if len(likes) > limit {
+		likes = likes[:limit]
+		last := likes[limit-1]
+		cfg.setPageLinks(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.UserID}.String(), "")
+	}
+
+	responseBody := []ChirpLike{}
+	for _, like := range likes {
+		responseBody = append(responseBody, ChirpLike{UserId: like.UserID, LikedAt: like.CreatedAt})
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
+
+// handlerUserLikes lists the chirps a user liked, most recently liked first.
+func (cfg *apiConfig) handlerUserLikes(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+	query := r.URL.Query()
+	limit, err := parsePageSize(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params := database.ListLikedChirpsParams{UserID: userId, MaxResults: int32(limit + 1)}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := parsePageCursor(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	rows, err := cfg.db.ListLikedChirps(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get liked chirps from db", err)
+		return
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		cfg.setPageLinks(w, r, pageCursor{CreatedAt: last.LikedAt, ID: last.ID}.String(), "")
+	}
+
+	responseBody := []Chirp{}
+	for _, row := range rows {
+		responseBody = append(responseBody, chirpFromDB(database.Chirp{
+			ID:            row.ID,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     row.UpdatedAt,
+			Body:          row.Body,
+			UserID:        row.UserID,
+			RevisionCount: row.RevisionCount,
+			ReplyToID:     row.ReplyToID,
+			ReplyCount:    row.ReplyCount,
+			RechirpOfID:   row.RechirpOfID,
+			QuoteOfID:     row.QuoteOfID,
+			LikeCount:     row.LikeCount,
+		}))
+	}
+	if err := cfg.decorateChirps(r, responseBody); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details from db", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+func TestChirpLikesFollowsRechirp(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	original := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "hello", UserID: uuid.New(), LikeCount: 1}
+	rechirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), UserID: uuid.New(),
+		RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true}}
+	likerId := uuid.New()
+	mock.ExpectQuery("GetChirpById").WithArgs(rechirp.ID).
+		WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(rechirp)...))
+	mock.ExpectQuery("GetChirpById").WithArgs(original.ID).
+		WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(original)...))
+	mock.ExpectQuery("ListChirpLikes").WithArgs(original.ID, nil, nil, defaultPageSize+1).
+		WillReturnRows(sqlmock.NewRows([]string{"user_id", "created_at"}).AddRow(likerId, time.Now()))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+rechirp.ID.String()+"/likes", nil)
+	req.SetPathValue("chirpID", rechirp.ID.String())
+	rec := httptest.NewRecorder()
+	cfg.handlerChirpLikes(rec, req)
+
+	var got []ChirpLike
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if len(got) != 1 || got[0].UserId != likerId {
+		t.Errorf("got %+v, want the likes of the original chirp", got)
+	}
+}
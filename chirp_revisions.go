@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+type ChirpRevision struct {
+	Id         uuid.UUID `json:"id"`
+	Body       string    `json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	ReplacedAt time.Time `json:"replaced_at"`
+}
+
+// editWindow is how long after posting a user may edit a chirp.
+func (cfg *apiConfig) editWindow(user database.User) time.Duration {
+	if user.IsChirpyRed.Bool {
+		return cfg.chirpyRedEditWindow
+	}
+	return cfg.chirpEditWindow
+}
+
+func (cfg *apiConfig) handlerEditChirp(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Body string `json:"body"`
+	}
+
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err = decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	newText, err := cleanChirpBody(reqBody.Body)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(context.Background(), principalFrom(r.Context()).UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
+		return
+	}
+	if cfg.requireEmailVerification && !user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "email address must be verified before editing", nil)
+		return
+	}
+	chirp, err := cfg.db.GetChirpById(context.Background(), chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+	if chirp.UserID != user.ID {
+		respondWithError(w, http.StatusForbidden, "user is not chrip owner", nil)
+		return
+	}
+	if chirp.RechirpOfID.Valid {
+		respondWithError(w, http.StatusBadRequest, "rechirps can't be edited", nil)
+		return
+	}
+	if time.Since(chirp.CreatedAt) > cfg.editWindow(user) {
+		respondWithError(w, http.StatusForbidden, "chirps can only be edited for "+cfg.editWindow(user).String()+" after posting", nil)
+		return
+	}
+	if newText == chirp.Body {
+		cfg.respondWithChirp(w, r, http.StatusOK, chirp)
+		return
+	}
+
+	edited, err := cfg.db.EditChirp(context.Background(), database.EditChirpParams{
+		ID:     chirp.ID,
+		UserID: user.ID,
+		Body:   newText,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Could not edit chirp", err)
+		return
+	}
+	cfg.indexHashtags(edited)
+	cfg.respondWithChirp(w, r, http.StatusOK, edited)
+}
+
+// handlerChirpRevisions lists the bodies a chirp had before each edit, oldest
+// first.
+func (cfg *apiConfig) handlerChirpRevisions(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	if _, err := cfg.db.GetChirpById(context.Background(), chirpId); errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+
+	revisions, err := cfg.db.GetChirpRevisions(context.Background(), chirpId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get revisions from db", err)
+		return
+	}
+
+	responseBody := []ChirpRevision{}
+	for _, revision := range revisions {
+		responseBody = append(responseBody, ChirpRevision{
+			Id:         revision.ID,
+			Body:       revision.Body,
+			CreatedAt:  revision.CreatedAt,
+			ReplacedAt: revision.ReplacedAt,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestEditChirpRequiresVerifiedEmail(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	cfg.requireEmailVerification = true
+	user := unverifiedUser("user@example.com")
+	mock.ExpectQuery("GetUserById").WithArgs(user.ID).WillReturnRows(userRow(user))
+
+	chirpId := uuid.New()
+	req := httptest.NewRequest(http.MethodPut, "/api/chirps/"+chirpId.String(), strings.NewReader(`{"body": "changed"}`))
+	req.SetPathValue("chirpID", chirpId.String())
+	req = asUser(req, user.ID)
+	rec := httptest.NewRecorder()
+	cfg.handlerEditChirp(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got %d: %s, want 403", rec.Code, rec.Body)
+	}
+}
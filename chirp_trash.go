@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// handlerChirpTrash lists the chirps the caller deleted that can still be
+// restored, most recently deleted first.
+func (cfg *apiConfig) handlerChirpTrash(w http.ResponseWriter, r *http.Request) {
+	chirps, err := cfg.db.ListDeletedChirps(context.Background(), database.ListDeletedChirpsParams{
+		UserID:       principalFrom(r.Context()).UserID,
+		DeletedAfter: time.Now().UTC().Add(-cfg.chirpRetention),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get deleted chirps from db", err)
+		return
+	}
+
+	responseBody := []Chirp{}
+	for _, chirp := range chirps {
+		responseBody = append(responseBody, chirpFromDB(chirp))
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
+
+// handlerRestoreChirp undoes an author's own delete. Chirps removed by a
+// moderator stay deleted.
+func (cfg *apiConfig) handlerRestoreChirp(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	userId := principalFrom(r.Context()).UserID
+
+	chirp, err := cfg.db.GetDeletedChirp(context.Background(), chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "no deleted chirp with ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+	if chirp.UserID != userId {
+		respondWithError(w, http.StatusForbidden, "user is not chrip owner", nil)
+		return
+	}
+	if chirp.DeletedBy.UUID != userId {
+		respondWithError(w, http.StatusForbidden, "chirp was removed by a moderator", nil)
+		return
+	}
+
+	restored, err := cfg.db.RestoreChirp(context.Background(), database.RestoreChirpParams{
+		ID:           chirpId,
+		DeletedAfter: time.Now().UTC().Add(-cfg.chirpRetention),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusGone, "chirp was deleted too long ago to restore", err)
+		return
+	} else if isUniqueViolation(err) {
+		// A deleted rechirp can't come back once its author has rechirped
+		// the same chirp again.
+		respondWithError(w, http.StatusConflict, "chirp is already rechirped", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to restore chirp", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirpFromDB(restored))
+}
+
+// purgeDeletedChirps permanently removes chirps deleted longer ago than the
+// retention window, checking every interval until the process exits.
+func (cfg *apiConfig) purgeDeletedChirps(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		cfg.purgeExpiredChirps(time.Now().UTC().Add(-cfg.chirpRetention))
+		<-ticker.C
+	}
+}
+
+// purgeExpiredChirps removes chirps deleted before deletedBefore. A deleted
+// chirp is only removed once it has no replies left.
+func (cfg *apiConfig) purgeExpiredChirps(deletedBefore time.Time) {
+	purged, err := cfg.db.PurgeDeletedChirps(context.Background(), deletedBefore)
+	if err != nil {
+		log.Printf("unable to purge deleted chirps: %v", err)
+	} else if purged > 0 {
+		log.Printf("purged %d deleted chirps", purged)
+	}
+	// Chirps with replies stay as placeholders in their thread, but lose
+	// their content.
+	if _, err := cfg.db.ScrubDeletedChirps(context.Background(), deletedBefore); err != nil {
+		log.Printf("unable to scrub deleted chirps: %v", err)
+	}
+}
+
+// isUniqueViolation reports whether err is Postgres rejecting a duplicate
+// key.
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+// around matches a time argument within a second of want.
+type around struct{ want time.Time }
+
+func (a around) Match(v driver.Value) bool {
+	got, ok := v.(time.Time)
+	return ok && got.Sub(a.want).Abs() < time.Second
+}
+
+func deletedChirp(userId, deletedBy uuid.UUID) database.Chirp {
+	return database.Chirp{
+		ID:        uuid.New(),
+		CreatedAt: time.Now().Add(-time.Hour),
+		UpdatedAt: time.Now().Add(-time.Hour),
+		Body:      "oops",
+		UserID:    userId,
+		DeletedAt: sql.NullTime{Time: time.Now().Add(-time.Minute), Valid: true},
+		DeletedBy: uuid.NullUUID{UUID: deletedBy, Valid: true},
+	}
+}
+
+func restoreRequest(chirpId, userId uuid.UUID) *http.Request {
+	req := httptest.NewRequest(http.MethodPost, "/api/chirps/"+chirpId.String()+"/restore", nil)
+	req.SetPathValue("chirpID", chirpId.String())
+	return asUser(req, userId)
+}
+
+func TestRestoreChirp(t *testing.T) {
+	userId := uuid.New()
+	tests := []struct {
+		name       string
+		chirp      database.Chirp
+		restoreErr error
+		wantCode   int
+	}{
+		{"own delete", deletedChirp(userId, userId), nil, http.StatusOK},
+		{"someone else's chirp", deletedChirp(uuid.New(), uuid.New()), nil, http.StatusForbidden},
+		{"removed by a moderator", deletedChirp(userId, uuid.New()), nil, http.StatusForbidden},
+		{"past retention", deletedChirp(userId, userId), sql.ErrNoRows, http.StatusGone},
+		{"rechirped again since", deletedChirp(userId, userId), &pq.Error{Code: "23505"}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mock, _ := newMockConfig(t)
+			cfg.chirpRetention = 24 * time.Hour
+			mock.ExpectQuery("GetDeletedChirp").WithArgs(tt.chirp.ID).
+				WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(tt.chirp)...))
+			if tt.chirp.UserID == userId && tt.chirp.DeletedBy.UUID == userId {
+				restore := mock.ExpectQuery("RestoreChirp").WithArgs(tt.chirp.ID, around{time.Now().Add(-24 * time.Hour)})
+				if tt.restoreErr != nil {
+					restore.WillReturnError(tt.restoreErr)
+				} else {
+					restored := tt.chirp
+					restored.DeletedAt, restored.DeletedBy = sql.NullTime{}, uuid.NullUUID{}
+					restore.WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(restored)...))
+				}
+			}
+
+			rec := httptest.NewRecorder()
+			cfg.handlerRestoreChirp(rec, restoreRequest(tt.chirp.ID, userId))
+			if rec.Code != tt.wantCode {
+				t.Errorf("got %d: %s, want %d", rec.Code, rec.Body, tt.wantCode)
+			}
+		})
+	}
+}
+
+func TestChirpTrash(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	cfg.chirpRetention = 24 * time.Hour
+	userId := uuid.New()
+	chirp := deletedChirp(userId, userId)
+	mock.ExpectQuery("ListDeletedChirps").WithArgs(userId, around{time.Now().Add(-24 * time.Hour)}).
+		WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(chirp)...))
+
+	rec := httptest.NewRecorder()
+	cfg.handlerChirpTrash(rec, asUser(httptest.NewRequest(http.MethodGet, "/api/chirps/trash", nil), userId))
+
+	var got []Chirp
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if len(got) != 1 || got[0].Id != chirp.ID || got[0].DeletedAt == nil || got[0].Body != chirp.Body {
+		t.Errorf("got %+v, want the deleted chirp with its body", got)
+	}
+}
+
+func TestPurgeExpiredChirps(t *testing.T) {
+	cfg, mock, executed := newMockConfig(t)
+	deletedBefore := time.Now().Add(-30 * 24 * time.Hour)
+	mock.ExpectExec("PurgeDeletedChirps").WithArgs(deletedBefore).WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec("ScrubDeletedChirps").WithArgs(deletedBefore).WillReturnResult(sqlmock.NewResult(0, 1))
+	cfg.purgeExpiredChirps(deletedBefore)
+
+	// Deleting a chirp would cascade to other users' rechirps of it.
+	for _, column := range []string{"reply_to_id", "rechirp_of_id", "quote_of_id"} {
+		if !strings.Contains((*executed)[0], column) {
+			t.Errorf("PurgeDeletedChirps deletes chirps still referenced by %s", column)
+		}
+	}
+
+	// A failed purge still scrubs what it can.
+	cfg, mock, _ = newMockConfig(t)
+	mock.ExpectExec("PurgeDeletedChirps").WillReturnError(sql.ErrConnDone)
+	mock.ExpectExec("ScrubDeletedChirps").WithArgs(deletedBefore).WillReturnResult(sqlmock.NewResult(0, 0))
+	cfg.purgeExpiredChirps(deletedBefore)
+}
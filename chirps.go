@@ -2,13 +2,12 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
 	"regexp"
-	"sort"
-	"strings"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -24,75 +23,148 @@ type Chirp struct {
 	UpdatedAt time.Time `json:"updated_at"`
 	Body      string    `json:"body"`
 	UserId    uuid.UUID `json:"user_id"`
+	// Edited is set once the body has been changed since it was posted.
+	Edited        bool       `json:"edited"`
+	RevisionCount int32      `json:"revision_count"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	ReplyToId     *uuid.UUID `json:"reply_to_id"`
+	ReplyCount    int32      `json:"reply_count"`
+	RechirpOfId   *uuid.UUID `json:"rechirp_of_id,omitempty"`
+	QuoteOfId     *uuid.UUID `json:"quote_of_id,omitempty"`
+	// Original is the chirp rechirped or quoted.
+	Original  *Chirp `json:"original,omitempty"`
+	LikeCount int32  `json:"like_count"`
+	// LikedByMe is only set when the request is authenticated.
+	LikedByMe *bool `json:"liked_by_me,omitempty"`
 }
 
 func (cfg *apiConfig) handlerAllChirps(w http.ResponseWriter, r *http.Request) {
-	type resp []Chirp
+	query := r.URL.Query()
+	params := database.ListChirpsParams{}
 
-	authorId := r.URL.Query().Get("author_id")
-	sortDir := r.URL.Query().Get("sort")
+	if authorId := query.Get("author_id"); authorId != "" {
+		userId, err := uuid.Parse(authorId)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid author id", err)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: userId, Valid: true}
+	}
+
+	sortDir := query.Get("sort")
 	if sortDir == "" {
 		sortDir = "asc"
+	} else if sortDir != "asc" && sortDir != "desc" {
+		respondWithError(w, http.StatusBadRequest, "sort must be asc or desc", nil)
+		return
 	}
 
-	var chirps []database.Chirp
-	if authorId == "" {
-		allChirps, err := cfg.db.GetAllChirps(context.Background())
-		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't get all chirps from db", err)
-			return
-		}
-		chirps = allChirps
-	} else {
-		allChirps, err := cfg.db.GetAllChirpsForUser(context.Background(), uuid.MustParse(authorId))
+	since, ok, err := parseTimeParam(query, "since")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params.Since = sql.NullTime{Time: since, Valid: ok}
+	until, ok, err := parseTimeParam(query, "until")
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params.Until = sql.NullTime{Time: until, Valid: ok}
+
+	limit, err := parsePageSize(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	// One extra chirp tells us whether there is another page.
+	params.MaxResults = int32(limit + 1)
+
+	var cursor *pageCursor
+	if value := query.Get("cursor"); value != "" {
+		c, err := parsePageCursor(value)
 		if err != nil {
-			respondWithError(w, http.StatusInternalServerError, "Couldn't get all chirps for user from db", err)
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
 			return
 		}
-		chirps = allChirps
+		cursor = &c
+		params.CursorCreatedAt = sql.NullTime{Time: c.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: c.ID, Valid: true}
 	}
 
-	responseBody := resp{}
-	for _, _chirp := range chirps {
-		responseBody = append(responseBody, Chirp{
-			Id:        _chirp.ID,
-			CreatedAt: _chirp.CreatedAt,
-			UpdatedAt: _chirp.UpdatedAt,
-			Body:      _chirp.Body,
-			UserId:    _chirp.UserID,
-		})
+	// A previous page is read in the opposite order and then reversed.
+	backwards := cursor != nil && cursor.Before
+	var chirps []database.Chirp
+	if (sortDir == "asc") != backwards {
+		chirps, err = cfg.db.ListChirps(context.Background(), params)
+	} else {
+		chirps, err = cfg.db.ListChirpsDesc(context.Background(), database.ListChirpsDescParams(params))
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from db", err)
+		return
+	}
+	more := len(chirps) > limit
+	if more {
+		chirps = chirps[:limit]
+	}
+	if backwards {
+		slices.Reverse(chirps)
 	}
 
-	if sortDir == "asc" {
-		sort.Slice(responseBody, func(i, j int) bool { return responseBody[i].CreatedAt.Before(responseBody[j].CreatedAt) })
-	} else {
-		sort.Slice(responseBody, func(i, j int) bool { return responseBody[i].CreatedAt.After(responseBody[j].CreatedAt) })
+	var next, prev string
+	if len(chirps) > 0 {
+		hasNext, hasPrev := more, cursor != nil
+		if backwards {
+			hasNext, hasPrev = true, more
+		}
+		if first := chirps[0]; hasPrev {
+			prev = pageCursor{CreatedAt: first.CreatedAt, ID: first.ID, Before: true}.String()
+		}
+		if last := chirps[len(chirps)-1]; hasNext {
+			next = pageCursor{CreatedAt: last.CreatedAt, ID: last.ID}.String()
+		}
 	}
+	cfg.setPageLinks(w, r, next, prev)
 
+	responseBody := []Chirp{}
+	for _, chirp := range chirps {
+		responseBody = append(responseBody, chirpFromDB(chirp))
+	}
+	if err := cfg.decorateChirps(r, responseBody); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details from db", err)
+		return
+	}
 	respondWithJSON(w, http.StatusOK, responseBody)
 }
 
 func (cfg *apiConfig) handlerChirpById(w http.ResponseWriter, r *http.Request) {
-	reqChirpId := r.PathValue("chirpID")
-	chirpId := uuid.MustParse(reqChirpId)
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
 	chirp, err := cfg.db.GetChirpById(context.Background(), chirpId)
 	if err != nil {
 		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
 		return
 	}
 
-	respondWithJSON(w, http.StatusOK, Chirp{
-		Id:        chirp.ID,
-		CreatedAt: chirp.CreatedAt,
-		UpdatedAt: chirp.UpdatedAt,
-		Body:      chirp.Body,
-		UserId:    chirp.UserID,
-	})
+	cfg.respondWithChirp(w, r, http.StatusOK, chirp)
 }
 
 func (cfg *apiConfig) handlerChirps(w http.ResponseWriter, r *http.Request) {
+	cfg.createChirp(w, r, uuid.NullUUID{})
+}
+
+// createChirp posts the chirp in the request body, as a reply if replyTo is
+// set. Setting rechirp_of_id instead of a body reposts another chirp, and
+// setting quote_of_id as well as a body quotes it.
+func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request, replyTo uuid.NullUUID) {
 	type req struct {
-		Body string `json:"body"`
+		Body        string     `json:"body"`
+		RechirpOfId *uuid.UUID `json:"rechirp_of_id"`
+		QuoteOfId   *uuid.UUID `json:"quote_of_id"`
 	}
 
 	decoder := json.NewDecoder(r.Body)
@@ -103,93 +175,301 @@ func (cfg *apiConfig) handlerChirps(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	user, err := cfg.db.GetUserById(context.Background(), principalFrom(r.Context()).UserID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invalid authorization token provided", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
 		return
 	}
-
-	matches, _ := regexp.Match("[0-9a-f]{64}", []byte(token))
-	if matches {
-		respondWithError(w, http.StatusUnauthorized, "Attempting to use refresh token as access token", err)
+	if cfg.requireEmailVerification && !user.EmailVerifiedAt.Valid {
+		respondWithError(w, http.StatusForbidden, "email address must be verified before posting", nil)
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, cfg.signingSecret)
-	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invalid authorization token provided - invalid user", err)
+	if reqBody.RechirpOfId != nil {
+		if reqBody.Body != "" || reqBody.QuoteOfId != nil || replyTo.Valid {
+			respondWithError(w, http.StatusBadRequest, "a rechirp can't have a body, quote or reply to a chirp; quote it instead", nil)
+			return
+		}
+		original, err := cfg.originalChirp(*reqBody.RechirpOfId)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+reqBody.RechirpOfId.String(), err)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+			return
+		}
+		rechirp, err := cfg.db.CreateRechirp(context.Background(), database.CreateRechirpParams{
+			UserID:      user.ID,
+			RechirpOfID: uuid.NullUUID{UUID: original.ID, Valid: true},
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusConflict, "chirp is already rechirped", nil)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Could not create rechirp", err)
+			return
+		}
+		cfg.respondWithChirp(w, r, http.StatusCreated, rechirp)
 		return
 	}
 
-	user, err := cfg.db.GetUserById(context.Background(), userId)
+	newText, err := cleanChirpBody(reqBody.Body)
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
+		respondWithError(w, http.StatusBadRequest, err.Error(), nil)
 		return
 	}
 
-	const maxChirpLength = 140
-	if len(reqBody.Body) > maxChirpLength {
-		respondWithError(w, http.StatusBadRequest, "Chirp is too long", nil)
-		return
+	quoteOf := uuid.NullUUID{}
+	if reqBody.QuoteOfId != nil {
+		original, err := cfg.originalChirp(*reqBody.QuoteOfId)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+reqBody.QuoteOfId.String(), err)
+			return
+		} else if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+			return
+		}
+		quoteOf = uuid.NullUUID{UUID: original.ID, Valid: true}
 	}
 
-	regex := regexp.MustCompile("(?i)(kerfuffle)|(sharbert)|(fornax)")
-	newText := regex.ReplaceAllString(reqBody.Body, SUB_STRING)
-	matchCount := regex.FindAllString(reqBody.Body, -1)
-	fmt.Printf("Match count: %d, replacement text: %s\n", len(matchCount), newText)
-
 	newChirp, err := cfg.db.CreateChirp(context.Background(), database.CreateChirpParams{
-		Body:   newText,
-		UserID: user.ID,
+		Body:      newText,
+		UserID:    user.ID,
+		ReplyToID: replyTo,
+		QuoteOfID: quoteOf,
 	})
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Could not create chirp", err)
 		return
 	}
+	cfg.indexHashtags(newChirp)
 
-	respondWithJSON(w, http.StatusCreated, Chirp{
-		Id:        newChirp.ID,
-		CreatedAt: newChirp.CreatedAt,
-		UpdatedAt: newChirp.UpdatedAt,
-		Body:      newChirp.Body,
-		UserId:    newChirp.UserID,
-	})
+	cfg.respondWithChirp(w, r, http.StatusCreated, newChirp)
 }
 
-func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
-	authorization := r.Header.Get("Authorization")
-	if authorization == "" {
-		respondWithError(w, http.StatusUnauthorized, "no authorization header found", errors.New("no authorization header provided"))
+// originalChirp looks up a chirp that isn't deleted, following a rechirp to
+// the chirp it reposts.
+func (cfg *apiConfig) originalChirp(chirpId uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.db.GetChirpById(context.Background(), chirpId)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if chirp.RechirpOfID.Valid {
+		return cfg.db.GetChirpById(context.Background(), chirp.RechirpOfID.UUID)
+	}
+	return chirp, nil
+}
+
+func (cfg *apiConfig) handlerUndoRechirp(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
 		return
 	}
-	token := strings.Split(authorization, "Bearer ")[1]
-	userId, err := auth.ValidateJWT(token, cfg.signingSecret)
+
+	deleted, err := cfg.db.DeleteRechirp(context.Background(), database.DeleteRechirpParams{
+		UserID:      principalFrom(r.Context()).UserID,
+		RechirpOfID: uuid.NullUUID{UUID: chirpId, Valid: true},
+	})
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "invalid JWT token", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to undo rechirp", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "chirp is not rechirped", nil)
 		return
 	}
+	w.WriteHeader(http.StatusNoContent)
+}
 
-	reqChirpId := r.PathValue("chirpID")
-	chirpId := uuid.MustParse(reqChirpId)
+func (cfg *apiConfig) handlerDeleteChirp(w http.ResponseWriter, r *http.Request) {
+	p := principalFrom(r.Context())
 
-	chirp, err := cfg.db.GetChirpById(context.Background(), chirpId)
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, "Unable to fetch chrip by ID: "+chirpId.String(), err)
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
 		return
 	}
 
-	if chirp.UserID != userId {
-		respondWithError(w, http.StatusForbidden, "user is not chrip owner", err)
+	chirp, err := cfg.db.GetChirpById(context.Background(), chirpId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Unable to fetch chrip by ID: "+chirpId.String(), err)
 		return
 	}
 
-	err = cfg.db.DeleteChripById(context.Background(), database.DeleteChripByIdParams{
-		ID:     chirpId,
-		UserID: userId,
+	// Moderators may delete anyone's chirps, but only with their own access
+	// token: personal access and OAuth tokens carry no permissions.
+	if chirp.UserID != p.UserID {
+		if !p.Access.HasPermission(auth.PermissionChirpsModerate) {
+			respondWithError(w, http.StatusForbidden, "user is not chrip owner", err)
+			return
+		}
+	}
+
+	// The chirp is only tombstoned, so its author can restore it until
+	// the purger removes it.
+	deleted, err := cfg.db.SoftDeleteChirp(context.Background(), database.SoftDeleteChirpParams{
+		ID:        chirpId,
+		DeletedBy: uuid.NullUUID{UUID: p.UserID, Valid: true},
 	})
 	if err != nil {
-		respondWithError(w, http.StatusForbidden, "Unable to delete chrip by ID: "+chirpId.String(), err)
+		respondWithError(w, http.StatusInternalServerError, "Unable to delete chrip by ID: "+chirpId.String(), err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "Unable to fetch chrip by ID: "+chirpId.String(), nil)
 		return
 	}
 	w.WriteHeader(http.StatusNoContent)
 }
+
+func chirpFromDB(chirp database.Chirp) Chirp {
+	responseBody := Chirp{
+		Id:        chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+		UpdatedAt: chirp.UpdatedAt,
+		Body:      chirp.Body,
+		UserId:    chirp.UserID,
+
+		Edited:        chirp.RevisionCount > 0,
+		RevisionCount: chirp.RevisionCount,
+		ReplyCount:    chirp.ReplyCount,
+		LikeCount:     chirp.LikeCount,
+	}
+	if chirp.DeletedAt.Valid {
+		responseBody.DeletedAt = &chirp.DeletedAt.Time
+	}
+	if chirp.ReplyToID.Valid {
+		responseBody.ReplyToId = &chirp.ReplyToID.UUID
+	}
+	if chirp.RechirpOfID.Valid {
+		responseBody.RechirpOfId = &chirp.RechirpOfID.UUID
+	}
+	if chirp.QuoteOfID.Valid {
+		responseBody.QuoteOfId = &chirp.QuoteOfID.UUID
+	}
+	return responseBody
+}
+
+// chirpOrPlaceholder shows a deleted chirp as a placeholder, without its body
+// or author, so that chirps replying to, rechirping or quoting it still make
+// sense.
+func chirpOrPlaceholder(chirp database.Chirp) Chirp {
+	responseBody := chirpFromDB(chirp)
+	if responseBody.DeletedAt != nil {
+		responseBody.Body = ""
+		responseBody.UserId = uuid.Nil
+		responseBody.Edited = false
+		responseBody.RevisionCount = 0
+	}
+	return responseBody
+}
+
+// embedOriginals fills in the Original of every rechirp and quote chirp.
+func (cfg *apiConfig) embedOriginals(chirps []Chirp) error {
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		if chirp.RechirpOfId != nil {
+			ids = append(ids, *chirp.RechirpOfId)
+		} else if chirp.QuoteOfId != nil {
+			ids = append(ids, *chirp.QuoteOfId)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	originals, err := cfg.db.GetChirpsByIds(context.Background(), ids)
+	if err != nil {
+		return err
+	}
+	byId := map[uuid.UUID]Chirp{}
+	for _, original := range originals {
+		byId[original.ID] = chirpOrPlaceholder(original)
+	}
+	for i, chirp := range chirps {
+		id := chirp.QuoteOfId
+		if chirp.RechirpOfId != nil {
+			id = chirp.RechirpOfId
+		}
+		if id == nil {
+			continue
+		}
+		if original, ok := byId[*id]; ok {
+			chirps[i].Original = &original
+		}
+	}
+	return nil
+}
+
+// markLikedByMe sets LikedByMe on chirps and the chirps they embed when the
+// request is authenticated.
+func (cfg *apiConfig) markLikedByMe(r *http.Request, chirps []Chirp) error {
+	userId := principalFrom(r.Context()).UserID
+	if userId == uuid.Nil {
+		return nil
+	}
+
+	ids := []uuid.UUID{}
+	for _, chirp := range chirps {
+		ids = append(ids, chirp.Id)
+		if chirp.Original != nil {
+			ids = append(ids, chirp.Original.Id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	likedIds, err := cfg.db.GetLikedChirpIds(context.Background(), database.GetLikedChirpIdsParams{
+		UserID:   userId,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	liked := map[uuid.UUID]bool{}
+	for _, id := range likedIds {
+		liked[id] = true
+	}
+	for i := range chirps {
+		likedByMe := liked[chirps[i].Id]
+		chirps[i].LikedByMe = &likedByMe
+		if original := chirps[i].Original; original != nil {
+			likedByMe := liked[original.Id]
+			original.LikedByMe = &likedByMe
+		}
+	}
+	return nil
+}
+
+// decorateChirps adds what a Chirp shows beyond its own row: the chirps it
+// rechirps or quotes, and whether the caller likes them.
+func (cfg *apiConfig) decorateChirps(r *http.Request, chirps []Chirp) error {
+	if err := cfg.embedOriginals(chirps); err != nil {
+		return err
+	}
+	return cfg.markLikedByMe(r, chirps)
+}
+
+func (cfg *apiConfig) respondWithChirp(w http.ResponseWriter, r *http.Request, code int, chirp database.Chirp) {
+	responseBody := []Chirp{chirpFromDB(chirp)}
+	if err := cfg.decorateChirps(r, responseBody); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details from db", err)
+		return
+	}
+	respondWithJSON(w, code, responseBody[0])
+}
+
+var profanity = regexp.MustCompile("(?i)(kerfuffle)|(sharbert)|(fornax)")
+
+// cleanChirpBody checks the length of a new or edited chirp and censors its
+// profanity.
+func cleanChirpBody(body string) (string, error) {
+	const maxChirpLength = 140
+	if len(body) > maxChirpLength {
+		return "", errors.New("Chirp is too long")
+	}
+
+	return profanity.ReplaceAllString(body, SUB_STRING), nil
+}
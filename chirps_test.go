@@ -0,0 +1,67 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+func TestCleanChirpBody(t *testing.T) {
+	got, err := cleanChirpBody("What a Kerfuffle, fornax!")
+	if err != nil {
+		t.Fatalf("cleanChirpBody failed with error: %v", err)
+	}
+	if want := "What a ****, ****!"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if _, err := cleanChirpBody(strings.Repeat("a", 141)); err == nil {
+		t.Error("cleanChirpBody accepted a chirp over 140 characters")
+	}
+}
+
+func TestChirpOrPlaceholder(t *testing.T) {
+	parentId := uuid.New()
+	chirp := database.Chirp{
+		ID:            uuid.New(),
+		Body:          "hello",
+		UserID:        uuid.New(),
+		RevisionCount: 2,
+		ReplyToID:     uuid.NullUUID{UUID: parentId, Valid: true},
+		ReplyCount:    3,
+	}
+	got := chirpOrPlaceholder(chirp)
+	if got.Body != "hello" || got.UserId != chirp.UserID || got.ReplyToId == nil || *got.ReplyToId != parentId || got.ReplyCount != 3 {
+		t.Errorf("chirpOrPlaceholder changed a chirp that isn't deleted: %+v", got)
+	}
+
+	chirp.DeletedAt = sql.NullTime{Time: time.Now(), Valid: true}
+	got = chirpOrPlaceholder(chirp)
+	if got.Body != "" || got.UserId != uuid.Nil || got.Edited || got.DeletedAt == nil {
+		t.Errorf("chirpOrPlaceholder didn't hide a deleted chirp: %+v", got)
+	}
+	if got.Id != chirp.ID || got.ReplyToId == nil || got.ReplyCount != 3 {
+		t.Errorf("chirpOrPlaceholder dropped the thread structure of a deleted chirp: %+v", got)
+	}
+}
+
+func TestChirpHandlersRejectMalformedIds(t *testing.T) {
+	cfg, _, _ := newMockConfig(t)
+	for name, handler := range map[string]http.HandlerFunc{
+		"get":    cfg.handlerChirpById,
+		"delete": cfg.handlerDeleteChirp,
+	} {
+		req := asUser(httptest.NewRequest(http.MethodGet, "/api/chirps/nope", nil), uuid.New())
+		req.SetPathValue("chirpID", "nope")
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid chirp id") {
+			t.Errorf("%s: got %d: %s, want a JSON 400", name, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/jpheneger/chirpy/internal/mailer"
+)
+
+// newMockConfig returns an apiConfig backed by sqlmock. Expectations name the
+// sqlc query they stand for, such as mock.ExpectQuery("GetUserById"), and
+// every statement run is appended to the returned slice.
+func newMockConfig(t *testing.T) (*apiConfig, sqlmock.Sqlmock, *[]string) {
+	t.Helper()
+	executed := &[]string{}
+	matcher := sqlmock.QueryMatcherFunc(func(name, actual string) error {
+		if !strings.HasPrefix(actual, "-- name: "+name+" :") {
+			return fmt.Errorf("expected query %s, got %.60q", name, actual)
+		}
+		*executed = append(*executed, actual)
+		return nil
+	})
+	db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(matcher))
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	t.Cleanup(func() {
+		db.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Error(err)
+		}
+	})
+
+	keyring, err := auth.ParseKeyring("k1:secret")
+	if err != nil {
+		t.Fatalf("unable to parse keyring: %v", err)
+	}
+	cfg := &apiConfig{
+		db:           *database.New(db),
+		keyring:      keyring,
+		tokenHashKey: []byte("token-hash-key"),
+		publicURL:    "http://localhost:8080",
+		passwordHasher: auth.NewArgon2idHasher(auth.Argon2idParams{
+			Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32,
+		}),
+	}
+	return cfg, mock, executed
+}
+
+var userColumns = []string{"id", "created_at", "updated_at", "email", "hashed_password", "is_chirpy_red", "email_verified_at"}
+
+func userRow(user database.User) *sqlmock.Rows {
+	return sqlmock.NewRows(userColumns).AddRow(user.ID, user.CreatedAt, user.UpdatedAt, user.Email, user.HashedPassword, user.IsChirpyRed, user.EmailVerifiedAt)
+}
+
+var chirpColumns = []string{"id", "created_at", "updated_at", "body", "user_id", "search_vector", "revision_count", "deleted_at", "deleted_by", "reply_to_id", "reply_count", "rechirp_of_id", "quote_of_id", "like_count"}
+
+func chirpValues(chirp database.Chirp) []driver.Value {
+	return []driver.Value{chirp.ID, chirp.CreatedAt, chirp.UpdatedAt, chirp.Body, chirp.UserID, nil, chirp.RevisionCount, chirp.DeletedAt, chirp.DeletedBy, chirp.ReplyToID, chirp.ReplyCount, chirp.RechirpOfID, chirp.QuoteOfID, chirp.LikeCount}
+}
+
+// expectSession expects the queries respondWithSession runs to log a user in.
+func expectSession(mock sqlmock.Sqlmock, userId uuid.UUID) {
+	mock.ExpectQuery("GetUserRoles").WithArgs(userId).WillReturnRows(sqlmock.NewRows([]string{"role"}))
+	mock.ExpectQuery("GetUserPermissions").WithArgs(userId).WillReturnRows(sqlmock.NewRows([]string{"permission"}))
+	mock.ExpectQuery("CreateRefreshToken").WillReturnRows(sqlmock.NewRows([]string{
+		"token_hash", "created_at", "updated_at", "user_id", "expires_at", "revoked_at", "family_id", "last_used_at", "user_agent", "ip_address",
+	}).AddRow("hash", time.Now(), time.Now(), userId, time.Now().Add(time.Hour), nil, uuid.New(), nil, "", ""))
+}
+
+// unverifiedUser is an account whose address was never verified, so it may
+// have been registered by someone who doesn't own it.
+func unverifiedUser(email string) database.User {
+	return database.User{
+		ID:             uuid.New(),
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+		Email:          email,
+		HashedPassword: sql.NullString{String: "squatter's hash", Valid: true},
+	}
+}
+
+type discardMailer struct{}
+
+func (discardMailer) Send(ctx context.Context, msg mailer.Message) error {
+	return nil
+}
+
+// asUser returns r as the auth middlewares pass it on for a session of
+// userId.
+func asUser(r *http.Request, userId uuid.UUID) *http.Request {
+	p := principal{UserID: userId, Credential: credentialSession}
+	return r.WithContext(context.WithValue(r.Context(), principalKey{}, p))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+// maxTrendingHashtags is how many hashtags GET /api/trending returns.
+const maxTrendingHashtags = 20
+
+type TrendingHashtag struct {
+	Tag        string  `json:"tag"`
+	ChirpCount int64   `json:"chirp_count"`
+	Score      float64 `json:"score"`
+}
+
+// trendingHashtags is the latest result of the trending aggregator.
+type trendingHashtags struct {
+	ComputedAt time.Time         `json:"computed_at"`
+	Hashtags   []TrendingHashtag `json:"hashtags"`
+}
+
+// hashtagPattern matches a # at the start of the body or after anything that
+// can't be part of a word. The tag itself may run into a censored word, which
+// is why it also matches *.
+var hashtagPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])#([\p{L}\p{N}_*]+)`)
+
+// extractHashtags returns the distinct hashtags of a cleaned chirp body, lower
+// cased and without the #, in the order they first appear. Tags made only of
+// digits, and tags containing a censored word, are left out.
+func extractHashtags(body string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	for _, match := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tag := strings.ToLower(match[1])
+		if strings.Contains(tag, "*") || !strings.ContainsFunc(tag, unicode.IsLetter) || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// indexHashtags replaces the hashtags stored for a chirp with the ones in its
+// body. The chirp is already saved, so a failure is only logged.
+func (cfg *apiConfig) indexHashtags(chirp database.Chirp) {
+	err := cfg.db.SetChirpHashtags(context.Background(), database.SetChirpHashtagsParams{
+		Tags:      extractHashtags(chirp.Body),
+		ChirpID:   chirp.ID,
+		CreatedAt: chirp.CreatedAt,
+	})
+	if err != nil {
+		log.Printf("unable to index hashtags of chirp %s: %v", chirp.ID, err)
+	}
+}
+
+// handlerHashtagChirps lists the chirps tagged with a hashtag, newest first.
+// The tag is matched case insensitively, with or without its #.
+func (cfg *apiConfig) handlerHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	tag := strings.ToLower(strings.TrimPrefix(r.PathValue("tag"), "#"))
+	if tag == "" {
+		respondWithError(w, http.StatusBadRequest, "invalid hashtag", nil)
+		return
+	}
+
+	query := r.URL.Query()
+	limit, err := parsePageSize(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params := database.ListHashtagChirpsParams{
+		Tag:        tag,
+		MaxResults: int32(limit + 1),
+	}
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := parsePageCursor(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	dbChirps, err := cfg.db.ListHashtagChirps(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirps from db", err)
+		return
+	}
+	if len(dbChirps) > limit {
+		dbChirps = dbChirps[:limit]
+		last := dbChirps[limit-1]
+		cfg.setPageLinks(w, r, pageCursor{CreatedAt: last.CreatedAt, ID: last.ID}.String(), "")
+	}
+
+	chirps := []Chirp{}
+	for _, chirp := range dbChirps {
+		chirps = append(chirps, chirpFromDB(chirp))
+	}
+	if err := cfg.decorateChirps(r, chirps); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details from db", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, chirps)
+}
+
+// handlerTrending returns the hashtags with the highest scores from the last
+// run of the trending aggregator.
+func (cfg *apiConfig) handlerTrending(w http.ResponseWriter, r *http.Request) {
+	trending := cfg.trending.Load()
+	if trending == nil {
+		trending = &trendingHashtags{Hashtags: []TrendingHashtag{}}
+	}
+	respondWithJSON(w, http.StatusOK, trending)
+}
+
+// aggregateTrendingHashtags scores the hashtags used within the trending
+// window every interval, so that GET /api/trending only has to read the last
+// result.
+func (cfg *apiConfig) aggregateTrendingHashtags(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		rows, err := cfg.db.GetTrendingHashtags(context.Background(), database.GetTrendingHashtagsParams{
+			HalfLifeSeconds: cfg.trendingHalfLife.Seconds(),
+			WindowSeconds:   cfg.trendingWindow.Seconds(),
+			MaxResults:      maxTrendingHashtags,
+		})
+		if err != nil {
+			log.Printf("unable to compute trending hashtags: %v", err)
+		} else {
+			trending := &trendingHashtags{ComputedAt: time.Now().UTC(), Hashtags: []TrendingHashtag{}}
+			for _, row := range rows {
+				trending.Hashtags = append(trending.Hashtags, TrendingHashtag{
+					Tag:        row.Tag,
+					ChirpCount: row.ChirpCount,
+					Score:      row.Score,
+				})
+			}
+			cfg.trending.Store(trending)
+		}
+		<-ticker.C
+	}
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+func TestExtractHashtags(t *testing.T) {
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"#Go is fun, #go #golang_1.22!", []string{"go", "golang_1"}},
+		{"no tags here", []string{}},
+		{"email me@example.com#anchor and #123", []string{}},
+		{"(#café) #日本", []string{"café", "日本"}},
+	}
+	for _, test := range tests {
+		if got := extractHashtags(test.body); !slices.Equal(got, test.want) {
+			t.Errorf("extractHashtags(%q) = %q, want %q", test.body, got, test.want)
+		}
+	}
+
+	// Hashtags are taken from the censored body, so filtered words never
+	// become tags, even as part of a longer one.
+	body, err := cleanChirpBody("#Kerfuffle #superfornax #sharbert2 #ok")
+	if err != nil {
+		t.Fatalf("cleanChirpBody failed with error: %v", err)
+	}
+	if got, want := extractHashtags(body), []string{"ok"}; !slices.Equal(got, want) {
+		t.Errorf("extractHashtags(%q) = %q, want %q", body, got, want)
+	}
+}
+
+func TestIndexHashtags(t *testing.T) {
+	cfg, mock, executed := newMockConfig(t)
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), Body: "#Go and #go with #rust"}
+	mock.ExpectExec("SetChirpHashtags").WithArgs(pq.Array([]string{"go", "rust"}), chirp.ID, chirp.CreatedAt).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	cfg.indexHashtags(chirp)
+
+	// A tag another chirp inserted first must still be linked.
+	if len(*executed) != 1 || !strings.Contains((*executed)[0], "ON CONFLICT (tag) DO UPDATE") {
+		t.Errorf("SetChirpHashtags doesn't return the ids of existing tags: %v", *executed)
+	}
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -12,50 +14,42 @@ import (
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
 )
 
-func HashPassword(password string) (string, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		log.Fatalf("unable to generate hash for password: %s - %v", password, err)
-		return "", err
-	}
-
-	return string(hashedPassword), nil
-}
-
-func CheckPasswordHash(hash, password string) (bool, error) {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	if err != nil {
-		return false, err
-	}
-
-	return true, nil
-}
+const (
+	TokenIssuer   = "chirpy"
+	TokenAudience = "chirpy"
+)
 
-const MY_SECRET_KEY = "AllYourBase"
+// AccessTokenType marks access tokens, so that no other token Chirpy signs
+// can be used as one.
+const AccessTokenType = "access"
 
 type MyCustomClaims struct {
 	jwt.RegisteredClaims
+	TokenType string `json:"token_type"`
+	Access
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
-	mySigningKey := []byte(MY_SECRET_KEY)
-	claims := MyCustomClaims{
-		jwt.RegisteredClaims{
+func newClaims(userID uuid.UUID, expiresIn time.Duration) MyCustomClaims {
+	return MyCustomClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
 			// A usual scenario is to set the expiration time relative to the current time
 			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(expiresIn)),
 			IssuedAt:  jwt.NewNumericDate(time.Now().UTC()),
 			NotBefore: jwt.NewNumericDate(time.Now().UTC()),
-			Issuer:    "chirpy",
+			Issuer:    TokenIssuer,
 			Subject:   userID.String(),
 			ID:        "1",
-			Audience:  []string{userID.String()},
+			Audience:  []string{TokenAudience},
 		},
+		TokenType: AccessTokenType,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	ss, err := token.SignedString(mySigningKey)
+}
+
+func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, newClaims(userID, expiresIn))
+	ss, err := token.SignedString([]byte(tokenSecret))
 	if err != nil {
 		log.Fatal("unable to sign token", err)
 		return "", err
@@ -65,53 +59,102 @@ func MakeJWT(userID uuid.UUID, tokenSecret string, expiresIn time.Duration) (str
 }
 
 func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &MyCustomClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(MY_SECRET_KEY), nil
+	return parseJWT(tokenString, func(token *jwt.Token) (interface{}, error) {
+		if token.Method.Alg() != jwt.SigningMethodHS256.Alg() {
+			return nil, fmt.Errorf("unexpected signing method %s", token.Method.Alg())
+		}
+		return []byte(tokenSecret), nil
 	})
+}
+
+func parseJWT(tokenString string, keyFunc jwt.Keyfunc) (uuid.UUID, error) {
+	claims, err := parseClaims(tokenString, keyFunc)
 	if err != nil {
-		fmt.Printf("unable to parse token: %v\n", err)
 		return uuid.Nil, err
 	}
+	return uuid.Parse(claims.Subject)
+}
+
+func parseClaims(tokenString string, keyFunc jwt.Keyfunc) (*MyCustomClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &MyCustomClaims{}, keyFunc,
+		jwt.WithValidMethods([]string{
+			jwt.SigningMethodHS256.Alg(),
+			jwt.SigningMethodEdDSA.Alg(),
+			jwt.SigningMethodRS256.Alg(),
+		}),
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(TokenAudience),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, err
+	}
 
 	if claims, ok := token.Claims.(*MyCustomClaims); ok {
-		return uuid.MustParse(claims.Subject), nil
+		// jwt only checks nbf when it is present; we always set it.
+		if claims.NotBefore == nil {
+			return nil, errors.New("token has no nbf claim")
+		}
+		if claims.TokenType != AccessTokenType {
+			return nil, fmt.Errorf("token type %q is not an access token", claims.TokenType)
+		}
+		return claims, nil
 	} else {
-		log.Fatal("unknown claims type, cannot proceed")
-		return uuid.UUID{}, errors.New("Unable to get claims from token")
+		return nil, errors.New("Unable to get claims from token")
 	}
 }
 
+// ErrNoAuthorization means the request carried no credentials at all, as
+// opposed to malformed ones.
+var ErrNoAuthorization = errors.New("no authorization header provided")
+
 func GetBearerToken(headers http.Header) (string, error) {
+	return getAuthorization(headers, "Bearer")
+}
+
+// getAuthorization returns the credentials of an Authorization header using
+// scheme, which is matched case-insensitively.
+func getAuthorization(headers http.Header, scheme string) (string, error) {
 	authorization := headers.Get("Authorization")
-	if authorization != "" {
-		parts := strings.Split(authorization, "Bearer ")
-		token := parts[1]
-		return token, nil
-	} else {
-		return "", errors.New("no authorizzation header provided")
+	if authorization == "" {
+		return "", ErrNoAuthorization
+	}
+	gotScheme, credentials, ok := strings.Cut(authorization, " ")
+	if !ok || !strings.EqualFold(gotScheme, scheme) {
+		return "", fmt.Errorf("authorization header does not use the %s scheme", scheme)
 	}
+	credentials = strings.TrimSpace(credentials)
+	if credentials == "" {
+		return "", errors.New("authorization header has no credentials")
+	}
+	return credentials, nil
 }
 
 func MakeRefreshToken() (string, error) {
 	key := make([]byte, 32)
 	n, err := rand.Read(key)
 	if err != nil {
-		fmt.Printf("unable to read bytes - err: %v\n", err)
 		return "", err
 	} else if n == 0 {
-		fmt.Println("unable to read bytes - zero length")
 		return "", errors.New("unable to read bytes - zero length")
 	}
 	return hex.EncodeToString(key), nil
 }
 
+// HashToken is the keyed hash used to store refresh tokens at rest.
+func HashToken(key []byte, token string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// LegacyTokenHash matches the unkeyed hash that the 007 migration applied to
+// refresh tokens issued before keyed hashing.
+func LegacyTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
-	authorization := headers.Get("Authorization")
-	if authorization != "" {
-		parts := strings.Split(authorization, "ApiKey ")
-		token := parts[1]
-		return token, nil
-	} else {
-		return "", errors.New("no authorizzation header provided")
-	}
+	return getAuthorization(headers, "ApiKey")
 }
@@ -0,0 +1,309 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// SigningKey is a single key in a Keyring: either an HMAC Secret or an
+// Ed25519/RSA Private key. A zero RetireAt means the key never retires.
+type SigningKey struct {
+	ID       string
+	Secret   []byte
+	Private  crypto.Signer
+	RetireAt time.Time
+}
+
+func (k SigningKey) retired(now time.Time) bool {
+	return !k.RetireAt.IsZero() && now.After(k.RetireAt)
+}
+
+func (k SigningKey) method() jwt.SigningMethod {
+	switch k.Private.(type) {
+	case ed25519.PrivateKey:
+		return jwt.SigningMethodEdDSA
+	case *rsa.PrivateKey:
+		return jwt.SigningMethodRS256
+	default:
+		return jwt.SigningMethodHS256
+	}
+}
+
+func (k SigningKey) signKey() interface{} {
+	if k.Private != nil {
+		return k.Private
+	}
+	return k.Secret
+}
+
+func (k SigningKey) verifyKey() interface{} {
+	if k.Private != nil {
+		return k.Private.Public()
+	}
+	return k.Secret
+}
+
+// Keyring signs access tokens with one key and verifies them against every
+// key it holds, so tokens issued before a rotation stay valid until the old
+// key retires.
+type Keyring struct {
+	signingKID string
+	keys       map[string]SigningKey
+}
+
+func NewKeyring(signing SigningKey, verifyOnly ...SigningKey) (*Keyring, error) {
+	if len(signing.Secret) == 0 && signing.Private == nil {
+		return nil, errors.New("signing key has no secret")
+	}
+	if signing.retired(time.Now()) {
+		return nil, fmt.Errorf("signing key %q is already retired", signing.ID)
+	}
+
+	kr := &Keyring{
+		signingKID: signing.ID,
+		keys:       map[string]SigningKey{},
+	}
+	for _, key := range append([]SigningKey{signing}, verifyOnly...) {
+		if key.ID == "" {
+			return nil, errors.New("signing key has no key id")
+		}
+		if _, ok := kr.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		kr.keys[key.ID] = key
+	}
+	return kr, nil
+}
+
+// ParseKeyring builds a keyring from a SIGNING_KEYS value, see
+// ParseSigningKeys.
+func ParseKeyring(config string) (*Keyring, error) {
+	keys, err := ParseSigningKeys(config)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("no signing keys configured")
+	}
+	return NewKeyring(keys[0], keys[1:]...)
+}
+
+// SecretSigningKey is the key for a plain SIGNING_SECRET. The secret is used
+// as is, whatever characters it contains, with a kid derived from it.
+func SecretSigningKey(secret string) SigningKey {
+	return SigningKey{ID: KeyID([]byte(secret)), Secret: []byte(secret)}
+}
+
+// ParseSigningKeys reads a SIGNING_KEYS value: a comma separated list of
+// "kid:secret" entries, each optionally suffixed with "@<RFC 3339 time>" to
+// retire it. An entry without a kid gets one derived from its secret.
+func ParseSigningKeys(config string) ([]SigningKey, error) {
+	return parseKeyEntries(config, func(kid, secret string) (SigningKey, error) {
+		if kid == "" {
+			kid = KeyID([]byte(secret))
+		}
+		return SigningKey{ID: kid, Secret: []byte(secret)}, nil
+	})
+}
+
+// LoadPrivateKeys reads a SIGNING_PRIVATE_KEYS value: a comma separated list
+// of "kid:/path/to/key.pem" entries in the same format as ParseSigningKeys.
+func LoadPrivateKeys(config string) ([]SigningKey, error) {
+	return parseKeyEntries(config, func(kid, path string) (SigningKey, error) {
+		if kid == "" {
+			return SigningKey{}, fmt.Errorf("private key %s has no key id", path)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return SigningKey{}, err
+		}
+		private, err := ParsePrivateKeyPEM(data)
+		if err != nil {
+			return SigningKey{}, fmt.Errorf("private key %s: %w", path, err)
+		}
+		return SigningKey{ID: kid, Private: private}, nil
+	})
+}
+
+func parseKeyEntries(config string, makeKey func(kid, value string) (SigningKey, error)) ([]SigningKey, error) {
+	var keys []SigningKey
+	for _, entry := range strings.Split(config, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		var retireAt time.Time
+		if at := strings.LastIndex(entry, "@"); at != -1 {
+			t, err := time.Parse(time.RFC3339, entry[at+1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid retire time in signing key entry: %w", err)
+			}
+			retireAt = t
+			entry = entry[:at]
+		}
+		kid, value, ok := strings.Cut(entry, ":")
+		if !ok {
+			kid, value = "", entry
+		}
+
+		key, err := makeKey(kid, value)
+		if err != nil {
+			return nil, err
+		}
+		key.RetireAt = retireAt
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// ParsePrivateKeyPEM accepts PKCS#8 Ed25519 or RSA keys and PKCS#1 RSA keys.
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed interface{}
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block type %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch key := parsed.(type) {
+	case ed25519.PrivateKey:
+		return key, nil
+	case *rsa.PrivateKey:
+		if key.N.BitLen() < 2048 {
+			return nil, errors.New("RSA keys must be at least 2048 bits")
+		}
+		return key, nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", parsed)
+	}
+}
+
+// KeyID derives a stable, non-secret key id from a secret.
+func KeyID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:4])
+}
+
+// MakeJWT issues an access token carrying the user's roles and permissions.
+func (kr *Keyring) MakeJWT(userID uuid.UUID, access Access, expiresIn time.Duration) (string, error) {
+	claims := newClaims(userID, expiresIn)
+	claims.Access = access
+	return kr.sign(claims)
+}
+
+func (kr *Keyring) sign(claims jwt.Claims) (string, error) {
+	key := kr.keys[kr.signingKID]
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.signKey())
+}
+
+func (kr *Keyring) ValidateJWT(tokenString string) (uuid.UUID, error) {
+	return parseJWT(tokenString, kr.keyFunc)
+}
+
+// ValidateAccessToken is ValidateJWT that also returns the roles and
+// permissions the token was issued with.
+func (kr *Keyring) ValidateAccessToken(tokenString string) (uuid.UUID, Access, error) {
+	claims, err := parseClaims(tokenString, kr.keyFunc)
+	if err != nil {
+		return uuid.Nil, Access{}, err
+	}
+	userID, err := uuid.Parse(claims.Subject)
+	if err != nil {
+		return uuid.Nil, Access{}, err
+	}
+	return userID, claims.Access, nil
+}
+
+func (kr *Keyring) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok || kid == "" {
+		return nil, errors.New("token has no key id")
+	}
+	key, ok := kr.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+	if key.retired(time.Now()) {
+		return nil, fmt.Errorf("key %q is retired", kid)
+	}
+	if token.Method.Alg() != key.method().Alg() {
+		return nil, fmt.Errorf("key %q does not sign with %s", kid, token.Method.Alg())
+	}
+	return key.verifyKey(), nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes the public half of every unretired asymmetric key. HMAC
+// secrets are never included.
+func (kr *Keyring) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	now := time.Now()
+	for _, key := range kr.keys {
+		if key.Private == nil || key.retired(now) {
+			continue
+		}
+		jwk := JWK{
+			Kid: key.ID,
+			Use: "sig",
+			Alg: key.method().Alg(),
+		}
+		switch pub := key.Private.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+	return set
+}
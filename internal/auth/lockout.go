@@ -0,0 +1,25 @@
+package auth
+
+import "time"
+
+// LockoutPolicy allows Threshold failed logins for free, then locks for Base
+// after the next failure, doubling with every further failure up to Max.
+type LockoutPolicy struct {
+	Threshold int
+	Base      time.Duration
+	Max       time.Duration
+}
+
+func (p LockoutPolicy) Lockout(failures int) time.Duration {
+	if failures <= p.Threshold {
+		return 0
+	}
+	lockout := p.Base
+	for i := p.Threshold + 1; i < failures; i++ {
+		lockout *= 2
+		if lockout >= p.Max {
+			return p.Max
+		}
+	}
+	return min(lockout, p.Max)
+}
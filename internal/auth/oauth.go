@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+)
+
+const (
+	oauthAccessTokenPrefix  = "chirpy_oat_"
+	oauthRefreshTokenPrefix = "chirpy_ort_"
+	oauthClientSecretPrefix = "chirpy_ocs_"
+)
+
+func MakeOAuthAccessToken() (string, error) {
+	return makePrefixedToken(oauthAccessTokenPrefix)
+}
+
+func MakeOAuthRefreshToken() (string, error) {
+	return makePrefixedToken(oauthRefreshTokenPrefix)
+}
+
+func MakeOAuthClientSecret() (string, error) {
+	return makePrefixedToken(oauthClientSecretPrefix)
+}
+
+func IsOAuthAccessToken(token string) bool {
+	return strings.HasPrefix(token, oauthAccessTokenPrefix)
+}
+
+func makePrefixedToken(prefix string) (string, error) {
+	token, err := MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	return prefix + token, nil
+}
+
+// ParseScope splits an OAuth scope parameter into its scopes, rejecting any
+// that Chirpy doesn't know and dropping duplicates.
+func ParseScope(scope string) ([]string, error) {
+	scopes := []string{}
+	for _, s := range strings.Fields(scope) {
+		if !ValidScope(s) {
+			return nil, fmt.Errorf("unknown scope %q", s)
+		}
+		if !HasScope(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return scopes, nil
+}
+
+const pkceVerifierChars = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-._~"
+
+// VerifyPKCE checks a code verifier against an S256 code challenge
+// (RFC 7636).
+func VerifyPKCE(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !strings.ContainsRune(pkceVerifierChars, c) {
+			return false
+		}
+	}
+	expected := PKCEChallenge(verifier)
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// PKCEChallenge derives the S256 code challenge for a code verifier.
+func PKCEChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,29 @@
+package auth
+
+import "slices"
+
+// Roles and permissions are stored in Postgres; these are the ones Chirpy
+// checks for.
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+
+	PermissionChirpsModerate = "chirps:moderate"
+	PermissionMetricsRead    = "metrics:read"
+	PermissionUsersUnlock    = "users:unlock"
+	PermissionRolesManage    = "roles:manage"
+	PermissionSystemReset    = "system:reset"
+)
+
+// Access is what a user may do, as carried in their access tokens. It is
+// fixed when the token is issued, so changes to a user's roles apply from
+// their next login or refresh.
+type Access struct {
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+}
+
+func (a Access) HasPermission(permission string) bool {
+	return slices.Contains(a.Permissions, permission)
+}
@@ -0,0 +1,132 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+// Single purpose tokens are signed by the keyring like access tokens but
+// carry their own audience, so none of them is accepted in place of another.
+const (
+	EmailVerificationAudience = "chirpy-email-verification"
+	MFAChallengeAudience      = "chirpy-mfa-challenge"
+	MagicLinkAudience         = "chirpy-magic-link"
+	OAuthConsentAudience      = "chirpy-oauth-consent"
+)
+
+type emailVerificationClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+type magicLinkClaims struct {
+	Email string `json:"email"`
+	jwt.RegisteredClaims
+}
+
+func purposeClaims(subject, audience string, expiresIn time.Duration) jwt.RegisteredClaims {
+	now := time.Now().UTC()
+	return jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Issuer:    TokenIssuer,
+		Subject:   subject,
+		Audience:  []string{audience},
+	}
+}
+
+func (kr *Keyring) verifyPurposeToken(tokenString, audience string, claims jwt.Claims) error {
+	_, err := jwt.ParseWithClaims(tokenString, claims, kr.keyFunc,
+		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(audience),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
+
+func (kr *Keyring) parsePurposeToken(tokenString, audience string, claims jwt.Claims) (uuid.UUID, error) {
+	err := kr.verifyPurposeToken(tokenString, audience, claims)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	subject, err := claims.GetSubject()
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return uuid.Parse(subject)
+}
+
+func (kr *Keyring) MakeEmailVerificationToken(userID uuid.UUID, email string, expiresIn time.Duration) (string, error) {
+	return kr.sign(emailVerificationClaims{
+		Email:            email,
+		RegisteredClaims: purposeClaims(userID.String(), EmailVerificationAudience, expiresIn),
+	})
+}
+
+// ValidateEmailVerificationToken returns the user and the address the token
+// was issued for.
+func (kr *Keyring) ValidateEmailVerificationToken(tokenString string) (uuid.UUID, string, error) {
+	claims := emailVerificationClaims{}
+	userID, err := kr.parsePurposeToken(tokenString, EmailVerificationAudience, &claims)
+	if err != nil {
+		return uuid.Nil, "", err
+	}
+	if claims.Email == "" {
+		return uuid.Nil, "", errors.New("token has no email claim")
+	}
+	return userID, claims.Email, nil
+}
+
+// MakeMFAChallengeToken proves that the bearer passed the password step of a
+// login, and nothing more.
+func (kr *Keyring) MakeMFAChallengeToken(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return kr.sign(purposeClaims(userID.String(), MFAChallengeAudience, expiresIn))
+}
+
+func (kr *Keyring) ValidateMFAChallengeToken(tokenString string) (uuid.UUID, error) {
+	return kr.parsePurposeToken(tokenString, MFAChallengeAudience, &jwt.RegisteredClaims{})
+}
+
+// MakeOAuthConsentToken lets the bearer approve or deny OAuth authorization
+// requests as the user, and do nothing else. The consent page signs in for
+// one instead of a full session, so approving an app leaves no session behind.
+func (kr *Keyring) MakeOAuthConsentToken(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	return kr.sign(purposeClaims(userID.String(), OAuthConsentAudience, expiresIn))
+}
+
+func (kr *Keyring) ValidateOAuthConsentToken(tokenString string) (uuid.UUID, error) {
+	return kr.parsePurposeToken(tokenString, OAuthConsentAudience, &jwt.RegisteredClaims{})
+}
+
+// MakeMagicLinkToken signs in whoever holds it as the owner of email, which
+// may not have an account yet. Its id lets the server accept it only once.
+func (kr *Keyring) MakeMagicLinkToken(email string, expiresIn time.Duration) (string, error) {
+	claims := magicLinkClaims{
+		Email:            email,
+		RegisteredClaims: purposeClaims(email, MagicLinkAudience, expiresIn),
+	}
+	claims.ID = uuid.NewString()
+	return kr.sign(claims)
+}
+
+// ValidateMagicLinkToken returns the token's id, the address it was sent to
+// and when it expires.
+func (kr *Keyring) ValidateMagicLinkToken(tokenString string) (uuid.UUID, string, time.Time, error) {
+	claims := magicLinkClaims{}
+	err := kr.verifyPurposeToken(tokenString, MagicLinkAudience, &claims)
+	if err != nil {
+		return uuid.Nil, "", time.Time{}, err
+	}
+	if claims.Email == "" {
+		return uuid.Nil, "", time.Time{}, errors.New("token has no email claim")
+	}
+	tokenID, err := uuid.Parse(claims.ID)
+	if err != nil {
+		return uuid.Nil, "", time.Time{}, errors.New("token has no valid id")
+	}
+	return tokenID, claims.Email, claims.ExpiresAt.Time, nil
+}
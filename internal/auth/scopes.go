@@ -0,0 +1,34 @@
+package auth
+
+import (
+	"slices"
+	"strings"
+)
+
+const (
+	ScopeChirpsRead   = "chirps:read"
+	ScopeChirpsWrite  = "chirps:write"
+	ScopeProfileWrite = "profile:write"
+)
+
+var Scopes = []string{ScopeChirpsRead, ScopeChirpsWrite, ScopeProfileWrite}
+
+func ValidScope(scope string) bool {
+	return slices.Contains(Scopes, scope)
+}
+
+func HasScope(granted []string, scope string) bool {
+	return slices.Contains(granted, scope)
+}
+
+// personalAccessTokenPrefix makes personal access tokens recognizable, both
+// to Chirpy and to secret scanners.
+const personalAccessTokenPrefix = "chirpy_pat_"
+
+func MakePersonalAccessToken() (string, error) {
+	return makePrefixedToken(personalAccessTokenPrefix)
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, personalAccessTokenPrefix)
+}
@@ -7,29 +7,41 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body      string
+	UserID    uuid.UUID
+	ReplyToID uuid.NullUUID
+	QuoteOfID uuid.NullUUID
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.ReplyToID,
+		arg.QuoteOfID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +49,56 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		&i.ReplyCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
+const createRechirp = `-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, rechirp_of_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
+    $2
+)
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL AND deleted_at IS NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
+`
+
+type CreateRechirpParams struct {
+	UserID      uuid.UUID
+	RechirpOfID uuid.NullUUID
+}
+
+func (q *Queries) CreateRechirp(ctx context.Context, arg CreateRechirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, createRechirp, arg.UserID, arg.RechirpOfID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		&i.ReplyCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.LikeCount,
 	)
 	return i, err
 }
@@ -50,29 +112,87 @@ func (q *Queries) DeleteChirps(ctx context.Context) error {
 	return err
 }
 
-const deleteChripById = `-- name: DeleteChripById :exec
+const deleteRechirp = `-- name: DeleteRechirp :execrows
 DELETE FROM chirps
-WHERE id = $1 and user_id = $2
+WHERE user_id = $1 AND rechirp_of_id = $2 AND deleted_at IS NULL
+`
+
+type DeleteRechirpParams struct {
+	UserID      uuid.UUID
+	RechirpOfID uuid.NullUUID
+}
+
+func (q *Queries) DeleteRechirp(ctx context.Context, arg DeleteRechirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteRechirp, arg.UserID, arg.RechirpOfID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const editChirp = `-- name: EditChirp :one
+WITH previous AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+    SELECT gen_random_uuid(), id, body, updated_at, NOW()
+    FROM chirps
+    WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+    FOR UPDATE
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET body = $3, updated_at = NOW(), revision_count = revision_count + 1
+FROM previous
+WHERE chirps.id = previous.chirp_id
+RETURNING chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.revision_count, chirps.deleted_at, chirps.deleted_by, chirps.reply_to_id, chirps.reply_count, chirps.rechirp_of_id, chirps.quote_of_id, chirps.like_count
 `
 
-type DeleteChripByIdParams struct {
+type EditChirpParams struct {
 	ID     uuid.UUID
 	UserID uuid.UUID
+	Body   string
 }
 
-func (q *Queries) DeleteChripById(ctx context.Context, arg DeleteChripByIdParams) error {
-	_, err := q.db.ExecContext(ctx, deleteChripById, arg.ID, arg.UserID)
-	return err
+func (q *Queries) EditChirp(ctx context.Context, arg EditChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, editChirp, arg.ID, arg.UserID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		&i.ReplyCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.LikeCount,
+	)
+	return i, err
 }
 
-const getAllChirps = `-- name: GetAllChirps :many
-SELECT id, created_at, updated_at, body, user_id
+const getChirpAncestors = `-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, reply_to_id, depth) AS (
+    SELECT id, reply_to_id, 0
+    FROM chirps
+    WHERE id = $1
+    UNION ALL
+    SELECT chirps.id, chirps.reply_to_id, ancestors.depth + 1
+    FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.reply_to_id
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.revision_count, chirps.deleted_at, chirps.deleted_by, chirps.reply_to_id, chirps.reply_count, chirps.rechirp_of_id, chirps.quote_of_id, chirps.like_count
 FROM chirps
-ORDER BY created_at ASC
+JOIN ancestors ON chirps.id = ancestors.id
+WHERE ancestors.depth > 0
+ORDER BY ancestors.depth DESC
 `
 
-func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirps)
+func (q *Queries) GetChirpAncestors(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpAncestors, id)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +206,79 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getChirpById = `-- name: GetChirpById :one
+SELECT id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
+FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getChirpById, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		&i.ReplyCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
+const getChirpRevisions = `-- name: GetChirpRevisions :many
+SELECT id, chirp_id, body, created_at, replaced_at
+FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at ASC
+`
+
+func (q *Queries) GetChirpRevisions(ctx context.Context, chirpID uuid.UUID) ([]ChirpRevision, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpRevisions, chirpID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []ChirpRevision
+	for rows.Next() {
+		var i ChirpRevision
+		if err := rows.Scan(
+			&i.ID,
+			&i.ChirpID,
+			&i.Body,
+			&i.CreatedAt,
+			&i.ReplacedAt,
 		); err != nil {
 			return nil, err
 		}
@@ -100,14 +293,14 @@ func (q *Queries) GetAllChirps(ctx context.Context) ([]Chirp, error) {
 	return items, nil
 }
 
-const getAllChirpsForUser = `-- name: GetAllChirpsForUser :many
-SELECT id, created_at, updated_at, body, user_id
+const getChirpsByIds = `-- name: GetChirpsByIds :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
 FROM chirps
-WHERE user_id = $1
+WHERE id = ANY($1::uuid[])
 `
 
-func (q *Queries) GetAllChirpsForUser(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getAllChirpsForUser, userID)
+func (q *Queries) GetChirpsByIds(ctx context.Context, ids []uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByIds, pq.Array(ids))
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +314,15 @@ func (q *Queries) GetAllChirpsForUser(ctx context.Context, userID uuid.UUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.SearchVector,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.LikeCount,
 		); err != nil {
 			return nil, err
 		}
@@ -135,14 +337,335 @@ func (q *Queries) GetAllChirpsForUser(ctx context.Context, userID uuid.UUID) ([]
 	return items, nil
 }
 
-const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id
+const getDeletedChirp = `-- name: GetDeletedChirp :one
+SELECT id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
 FROM chirps
-WHERE id = $1
+WHERE id = $1 AND deleted_at IS NOT NULL
 `
 
-func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, getChirpById, id)
+func (q *Queries) GetDeletedChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.SearchVector,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		&i.ReplyCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.LikeCount,
+	)
+	return i, err
+}
+
+const getReplyTree = `-- name: GetReplyTree :many
+WITH RECURSIVE replies (id, depth, path) AS (
+    -- path orders the tree depth first, and each level by (created_at, id).
+    SELECT id, 1, int8send((extract(epoch FROM created_at) * 1000000)::bigint) || uuid_send(id)
+    FROM chirps
+    WHERE reply_to_id = $1
+    UNION ALL
+    SELECT chirps.id, replies.depth + 1,
+        replies.path || int8send((extract(epoch FROM chirps.created_at) * 1000000)::bigint) || uuid_send(chirps.id)
+    FROM chirps
+    JOIN replies ON chirps.reply_to_id = replies.id
+)
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.revision_count, chirps.deleted_at, chirps.deleted_by, chirps.reply_to_id, chirps.reply_count, chirps.rechirp_of_id, chirps.quote_of_id, chirps.like_count, replies.depth, replies.path
+FROM chirps
+JOIN replies ON chirps.id = replies.id
+WHERE $2::bytea IS NULL OR replies.path > $2
+ORDER BY replies.path
+LIMIT $3
+`
+
+type GetReplyTreeRow struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Body          string
+	UserID        uuid.UUID
+	SearchVector  interface{}
+	RevisionCount int32
+	DeletedAt     sql.NullTime
+	DeletedBy     uuid.NullUUID
+	ReplyToID     uuid.NullUUID
+	ReplyCount    int32
+	RechirpOfID   uuid.NullUUID
+	QuoteOfID     uuid.NullUUID
+	LikeCount     int32
+	Depth         int32
+	Path          []byte
+}
+
+type GetReplyTreeParams struct {
+	ReplyToID  uuid.NullUUID
+	AfterPath  []byte
+	MaxResults int32
+}
+
+func (q *Queries) GetReplyTree(ctx context.Context, arg GetReplyTreeParams) ([]GetReplyTreeRow, error) {
+	rows, err := q.db.QueryContext(ctx, getReplyTree, arg.ReplyToID, arg.AfterPath, arg.MaxResults)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetReplyTreeRow
+	for rows.Next() {
+		var i GetReplyTreeRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.LikeCount,
+			&i.Depth,
+			&i.Path,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirps = `-- name: ListChirps :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
+FROM chirps
+WHERE deleted_at IS NULL
+    AND ($1::uuid IS NULL OR user_id = $1)
+    AND ($2::timestamp IS NULL OR created_at >= $2)
+    AND ($3::timestamp IS NULL OR created_at < $3)
+    AND ($4::timestamp IS NULL
+        OR (created_at, id) > ($4, $5::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT $6
+`
+
+type ListChirpsParams struct {
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	MaxResults      int32
+}
+
+func (q *Queries) ListChirps(ctx context.Context, arg ListChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirps,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listChirpsDesc = `-- name: ListChirpsDesc :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
+FROM chirps
+WHERE deleted_at IS NULL
+    AND ($1::uuid IS NULL OR user_id = $1)
+    AND ($2::timestamp IS NULL OR created_at >= $2)
+    AND ($3::timestamp IS NULL OR created_at < $3)
+    AND ($4::timestamp IS NULL
+        OR (created_at, id) < ($4, $5::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT $6
+`
+
+type ListChirpsDescParams struct {
+	AuthorID        uuid.NullUUID
+	Since           sql.NullTime
+	Until           sql.NullTime
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	MaxResults      int32
+}
+
+func (q *Queries) ListChirpsDesc(ctx context.Context, arg ListChirpsDescParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listChirpsDesc,
+		arg.AuthorID,
+		arg.Since,
+		arg.Until,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const listDeletedChirps = `-- name: ListDeletedChirps :many
+SELECT id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
+FROM chirps
+WHERE user_id = $1 AND deleted_by = user_id AND deleted_at > $2
+ORDER BY deleted_at DESC
+`
+
+type ListDeletedChirpsParams struct {
+	UserID       uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) ListDeletedChirps(ctx context.Context, arg ListDeletedChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, listDeletedChirps, arg.UserID, arg.DeletedAfter)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.LikeCount,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1
+    -- Chirps that are replied to, rechirped or quoted are only scrubbed, so
+    -- the others' chirps keep a placeholder and go through their own trash.
+    AND NOT EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.reply_to_id = chirps.id)
+    AND NOT EXISTS (SELECT 1 FROM chirps AS rechirps WHERE rechirps.rechirp_of_id = chirps.id)
+    AND NOT EXISTS (SELECT 1 FROM chirps AS quotes WHERE quotes.quote_of_id = chirps.id)
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1 AND deleted_at > $2
+RETURNING id, created_at, updated_at, body, user_id, search_vector, revision_count, deleted_at, deleted_by, reply_to_id, reply_count, rechirp_of_id, quote_of_id, like_count
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.DeletedAfter)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -150,6 +673,154 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.SearchVector,
+		&i.RevisionCount,
+		&i.DeletedAt,
+		&i.DeletedBy,
+		&i.ReplyToID,
+		&i.ReplyCount,
+		&i.RechirpOfID,
+		&i.QuoteOfID,
+		&i.LikeCount,
 	)
 	return i, err
 }
+
+const scrubDeletedChirps = `-- name: ScrubDeletedChirps :execrows
+WITH scrubbed AS (
+    UPDATE chirps
+    SET body = ''
+    WHERE deleted_at < $1 AND body <> ''
+    RETURNING id
+), unindexed AS (
+    DELETE FROM chirp_hashtags
+    WHERE chirp_id IN (SELECT id FROM scrubbed)
+), unbookmarked AS (
+    DELETE FROM chirp_bookmarks
+    WHERE chirp_id IN (SELECT id FROM scrubbed)
+)
+DELETE FROM chirp_revisions
+WHERE chirp_id IN (SELECT id FROM scrubbed)
+`
+
+func (q *Queries) ScrubDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, scrubDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const searchChirps = `-- name: SearchChirps :many
+SELECT chirps.id, chirps.created_at, chirps.updated_at, chirps.body, chirps.user_id, chirps.search_vector, chirps.revision_count, chirps.deleted_at, chirps.deleted_by, chirps.reply_to_id, chirps.reply_count, chirps.rechirp_of_id, chirps.quote_of_id, chirps.like_count,
+    ts_rank(search_vector, query) AS rank,
+    ts_headline(
+        'english',
+        replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        query,
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'
+    ) AS snippet
+FROM chirps, to_tsquery('english', $1) query
+WHERE search_vector @@ query
+    AND deleted_at IS NULL
+    AND ($2::uuid IS NULL OR user_id = $2)
+    AND ($3::real IS NULL
+        OR (ts_rank(search_vector, query), created_at, id)
+            < ($3, $4::timestamp, $5::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT $6
+`
+
+type SearchChirpsRow struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Body          string
+	UserID        uuid.UUID
+	SearchVector  interface{}
+	RevisionCount int32
+	DeletedAt     sql.NullTime
+	DeletedBy     uuid.NullUUID
+	ReplyToID     uuid.NullUUID
+	ReplyCount    int32
+	RechirpOfID   uuid.NullUUID
+	QuoteOfID     uuid.NullUUID
+	LikeCount     int32
+	Rank          float32
+	Snippet       string
+}
+
+type SearchChirpsParams struct {
+	Query           string
+	AuthorID        uuid.NullUUID
+	CursorRank      sql.NullFloat64
+	CursorCreatedAt sql.NullTime
+	CursorID        uuid.NullUUID
+	MaxResults      int32
+}
+
+func (q *Queries) SearchChirps(ctx context.Context, arg SearchChirpsParams) ([]SearchChirpsRow, error) {
+	rows, err := q.db.QueryContext(ctx, searchChirps,
+		arg.Query,
+		arg.AuthorID,
+		arg.CursorRank,
+		arg.CursorCreatedAt,
+		arg.CursorID,
+		arg.MaxResults,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []SearchChirpsRow
+	for rows.Next() {
+		var i SearchChirpsRow
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.SearchVector,
+			&i.RevisionCount,
+			&i.DeletedAt,
+			&i.DeletedBy,
+			&i.ReplyToID,
+			&i.ReplyCount,
+			&i.RechirpOfID,
+			&i.QuoteOfID,
+			&i.LikeCount,
+			&i.Rank,
+			&i.Snippet,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const softDeleteChirp = `-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = $2
+WHERE id = $1 AND deleted_at IS NULL
+`
+
+type SoftDeleteChirpParams struct {
+	ID        uuid.UUID
+	DeletedBy uuid.NullUUID
+}
+
+func (q *Queries) SoftDeleteChirp(ctx context.Context, arg SoftDeleteChirpParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, softDeleteChirp, arg.ID, arg.DeletedBy)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -11,28 +11,187 @@ import (
 	"github.com/google/uuid"
 )
 
+type ChirpBookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID
+	HashtagID uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpLike struct {
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type ChirpRevision struct {
+	ID         uuid.UUID
+	ChirpID    uuid.UUID
+	Body       string
+	CreatedAt  time.Time
+	ReplacedAt time.Time
+}
+
 type Chirp struct {
+	ID            uuid.UUID
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Body          string
+	UserID        uuid.UUID
+	SearchVector  interface{}
+	RevisionCount int32
+	DeletedAt     sql.NullTime
+	DeletedBy     uuid.NullUUID
+	ReplyToID     uuid.NullUUID
+	ReplyCount    int32
+	RechirpOfID   uuid.NullUUID
+	QuoteOfID     uuid.NullUUID
+	LikeCount     int32
+}
+
+type Hashtag struct {
 	ID        uuid.UUID
+	Tag       string
 	CreatedAt time.Time
-	UpdatedAt time.Time
-	Body      string
-	UserID    uuid.UUID
 }
 
-type RefreshToken struct {
-	Token     string
+type LoginThrottle struct {
+	Key           string
+	Failures      int32
+	LastFailureAt time.Time
+	LockedUntil   sql.NullTime
+}
+
+type MfaRecoveryCode struct {
+	CodeHash  string
+	UserID    uuid.UUID
 	CreatedAt time.Time
-	UpdatedAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string
+	GrantID       uuid.UUID
+	ClientID      string
+	UserID        uuid.UUID
+	RedirectUri   string
+	Scopes        []string
+	CodeChallenge string
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	UsedAt        sql.NullTime
+}
+
+type OauthClient struct {
+	ID           string
+	SecretHash   sql.NullString
+	Name         string
+	RedirectUris []string
+	OwnerID      uuid.UUID
+	CreatedAt    time.Time
+}
+
+type OauthToken struct {
+	TokenHash string
+	TokenType string
+	GrantID   uuid.UUID
+	ClientID  string
 	UserID    uuid.UUID
+	Scopes    []string
+	CreatedAt time.Time
 	ExpiresAt time.Time
 	RevokedAt sql.NullTime
 }
 
+type PasswordResetToken struct {
+	TokenHash string
+	UserID    uuid.UUID
+	CreatedAt time.Time
+	ExpiresAt time.Time
+	UsedAt    sql.NullTime
+}
+
+type Permission struct {
+	Name        string
+	Description string
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Name       string
+	TokenHash  string
+	Scopes     []string
+	CreatedAt  time.Time
+	ExpiresAt  sql.NullTime
+	LastUsedAt sql.NullTime
+	RevokedAt  sql.NullTime
+}
+
+type RefreshToken struct {
+	TokenHash  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	ExpiresAt  time.Time
+	RevokedAt  sql.NullTime
+	FamilyID   uuid.UUID
+	LastUsedAt sql.NullTime
+	UserAgent  string
+	IpAddress  string
+}
+
+type RolePermission struct {
+	Role       string
+	Permission string
+}
+
+type Role struct {
+	Name        string
+	Description string
+}
+
+type TotpCredential struct {
+	UserID          uuid.UUID
+	EncryptedSecret string
+	CreatedAt       time.Time
+	ConfirmedAt     sql.NullTime
+	LastUsedStep    int64
+}
+
+type UsedMagicLink struct {
+	TokenID   uuid.UUID
+	ExpiresAt time.Time
+	UsedAt    time.Time
+}
+
+type UserIdentity struct {
+	Issuer      string
+	Subject     string
+	UserID      uuid.UUID
+	Email       string
+	CreatedAt   time.Time
+	LastLoginAt time.Time
+}
+
+type UserRole struct {
+	UserID    uuid.UUID
+	Role      string
+	GrantedAt time.Time
+	GrantedBy uuid.NullUUID
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    sql.NullBool
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	Email           string
+	HashedPassword  sql.NullString
+	IsChirpyRed     sql.NullBool
+	EmailVerifiedAt sql.NullTime
 }
@@ -7,10 +7,96 @@ package database
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/google/uuid"
 )
 
+const claimUnverifiedUser = `-- name: ClaimUnverifiedUser :one
+WITH claimed AS (
+    -- Whoever signed up with an address that was never verified may not own
+    -- it, so everything that lets them back into the account goes in the
+    -- same statement that verifies it.
+    UPDATE users
+    SET hashed_password = NULL, email_verified_at = NOW(), updated_at = NOW()
+    WHERE id = $1 AND email = $2 AND email_verified_at IS NULL
+    RETURNING id
+), sessions AS (
+    UPDATE refresh_tokens
+    SET updated_at = NOW(), revoked_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND revoked_at IS NULL
+), pats AS (
+    UPDATE personal_access_tokens
+    SET revoked_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND revoked_at IS NULL
+), grants AS (
+    UPDATE oauth_tokens
+    SET revoked_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND revoked_at IS NULL
+), codes AS (
+    DELETE FROM oauth_authorization_codes
+    WHERE user_id IN (SELECT id FROM claimed)
+), clients AS (
+    DELETE FROM oauth_clients
+    WHERE owner_id IN (SELECT id FROM claimed)
+), totp AS (
+    DELETE FROM totp_credentials
+    WHERE user_id IN (SELECT id FROM claimed)
+), recovery_codes AS (
+    DELETE FROM mfa_recovery_codes
+    WHERE user_id IN (SELECT id FROM claimed)
+), resets AS (
+    UPDATE password_reset_tokens
+    SET used_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND used_at IS NULL
+), identities AS (
+    -- The caller links the identity it is claiming with afterwards.
+    DELETE FROM user_identities
+    WHERE user_id IN (SELECT id FROM claimed)
+)
+SELECT id FROM claimed
+`
+
+type ClaimUnverifiedUserParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) ClaimUnverifiedUser(ctx context.Context, arg ClaimUnverifiedUserParams) (uuid.UUID, error) {
+	row := q.db.QueryRowContext(ctx, claimUnverifiedUser, arg.ID, arg.Email)
+	var id uuid.UUID
+	err := row.Scan(&id)
+	return id, err
+}
+
+const createPasswordlessUser = `-- name: CreatePasswordlessUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, email_verified_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    NULL,
+    NOW()
+)
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at
+`
+
+func (q *Queries) CreatePasswordlessUser(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, createPasswordlessUser, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
+	)
+	return i, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -20,12 +106,12 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at
 `
 
 type CreateUserParams struct {
 	Email          string
-	HashedPassword string
+	HashedPassword sql.NullString
 }
 
 func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, error) {
@@ -38,6 +124,7 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
@@ -52,7 +139,7 @@ func (q *Queries) DeleteUsers(ctx context.Context) error {
 }
 
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at
 FROM users
 where email = $1
 `
@@ -67,12 +154,13 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const getUserById = `-- name: GetUserById :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at
 FROM users
 WHERE id = $1
 `
@@ -87,21 +175,23 @@ func (q *Queries) GetUserById(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
-SET email = $2, hashed_password = $3
+SET email = $2, hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END
 WHERE id = $1
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, email_verified_at
 `
 
 type UpdateUserParams struct {
 	ID             uuid.UUID
 	Email          string
-	HashedPassword string
+	HashedPassword sql.NullString
 }
 
 func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, error) {
@@ -114,10 +204,27 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.EmailVerifiedAt,
 	)
 	return i, err
 }
 
+const updateUserPassword = `-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type UpdateUserPasswordParams struct {
+	ID             uuid.UUID
+	HashedPassword sql.NullString
+}
+
+func (q *Queries) UpdateUserPassword(ctx context.Context, arg UpdateUserPasswordParams) error {
+	_, err := q.db.ExecContext(ctx, updateUserPassword, arg.ID, arg.HashedPassword)
+	return err
+}
+
 const upgradeUser = `-- name: UpgradeUser :exec
 UPDATE users
 SET is_chirpy_red = true
@@ -128,3 +235,22 @@ func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) error {
 	_, err := q.db.ExecContext(ctx, upgradeUser, id)
 	return err
 }
+
+const verifyUserEmail = `-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW())
+WHERE id = $1 AND email = $2
+`
+
+type VerifyUserEmailParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) VerifyUserEmail(ctx context.Context, arg VerifyUserEmailParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, verifyUserEmail, arg.ID, arg.Email)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
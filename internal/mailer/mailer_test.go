@@ -0,0 +1,72 @@
+package mailer
+
+import (
+	"context"
+	"io"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDirMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m := DirMailer{Dir: dir, From: "chirpy@localhost"}
+
+	msg := Message{
+		To:      "user@example.com",
+		Subject: "Reset your Chirpy password",
+		Body:    "Follow this link:\nhttp://localhost:8080/reset?token=abc\n",
+	}
+	for range 2 {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatalf("Send failed with error: %v", err)
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("got %d .eml files, want one per message", len(files))
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	parsed, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatalf("written message doesn't parse: %v", err)
+	}
+	for header, want := range map[string]string{
+		"From":    m.From,
+		"To":      msg.To,
+		"Subject": msg.Subject,
+	} {
+		if got := parsed.Header.Get(header); got != want {
+			t.Errorf("%s header = %q, want %q", header, got, want)
+		}
+	}
+	body, err := io.ReadAll(parsed.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.ReplaceAll(string(body), "\r\n", "\n"); got != msg.Body {
+		t.Errorf("body = %q, want %q", got, msg.Body)
+	}
+}
+
+func TestDirMailerRejectsHeaderInjection(t *testing.T) {
+	m := DirMailer{Dir: t.TempDir(), From: "chirpy@localhost"}
+	err := m.Send(context.Background(), Message{
+		To:      "user@example.com\r\nBcc: someone@example.com",
+		Subject: "hello",
+	})
+	if err == nil {
+		t.Error("Send accepted a recipient with a line break")
+	}
+}
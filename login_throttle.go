@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+var (
+	// Accounts lock quickly; addresses get more headroom because many users
+	// can share one behind NAT.
+	accountLockout = auth.LockoutPolicy{Threshold: 5, Base: 30 * time.Second, Max: 15 * time.Minute}
+	ipLockout      = auth.LockoutPolicy{Threshold: 20, Base: 30 * time.Second, Max: 1 * time.Hour}
+
+	// Endpoints that send mail to any address are limited the same way, per
+	// recipient and per client address, counting every request.
+	mailRecipientLimit = auth.LockoutPolicy{Threshold: 3, Base: 15 * time.Minute, Max: 1 * time.Hour}
+	mailIPLimit        = auth.LockoutPolicy{Threshold: 10, Base: 15 * time.Minute, Max: 1 * time.Hour}
+)
+
+func accountThrottleKey(email string) string {
+	return "email:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipThrottleKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// checkLoginThrottle responds with 429 and returns false while any of keys is
+// locked out.
+func (cfg *apiConfig) checkLoginThrottle(w http.ResponseWriter, keys ...string) bool {
+	return cfg.checkThrottle(w, "too many failed login attempts, try again later", keys...)
+}
+
+// throttleMail counts a request to send mail to email. It responds with 429
+// and returns false once the recipient or the client has asked too often.
+// The recipient's counter is separate from its login lockout, so requesting
+// mail can't lock anyone out of their account.
+func (cfg *apiConfig) throttleMail(w http.ResponseWriter, r *http.Request, email string) bool {
+	recipientKey := "mail:" + strings.ToLower(strings.TrimSpace(email))
+	ipKey := "mail-ip:" + clientIP(r)
+	if !cfg.checkThrottle(w, "too many emails requested, try again later", recipientKey, ipKey) {
+		return false
+	}
+	cfg.recordThrottleFailure(recipientKey, mailRecipientLimit)
+	cfg.recordThrottleFailure(ipKey, mailIPLimit)
+	return true
+}
+
+func (cfg *apiConfig) checkThrottle(w http.ResponseWriter, msg string, keys ...string) bool {
+	throttles, err := cfg.db.GetLoginThrottles(context.Background(), keys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch login throttles from db", err)
+		return false
+	}
+
+	var lockedUntil time.Time
+	for _, throttle := range throttles {
+		if throttle.LockedUntil.Valid && throttle.LockedUntil.Time.After(lockedUntil) {
+			lockedUntil = throttle.LockedUntil.Time
+		}
+	}
+	retryAfter := time.Until(lockedUntil)
+	if retryAfter <= 0 {
+		return true
+	}
+
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	respondWithError(w, http.StatusTooManyRequests, msg, nil)
+	return false
+}
+
+func (cfg *apiConfig) recordLoginFailure(r *http.Request, email string) {
+	cfg.recordThrottleFailure(accountThrottleKey(email), accountLockout)
+	cfg.recordThrottleFailure(ipThrottleKey(r), ipLockout)
+}
+
+func (cfg *apiConfig) recordThrottleFailure(key string, policy auth.LockoutPolicy) {
+	throttle, err := cfg.db.RecordLoginFailure(context.Background(), key)
+	if err != nil {
+		log.Printf("unable to record failed login for %s: %v", key, err)
+		return
+	}
+
+	lockout := policy.Lockout(int(throttle.Failures))
+	if lockout == 0 {
+		return
+	}
+	err = cfg.db.LockLogin(context.Background(), database.LockLoginParams{
+		Key:         key,
+		LockedUntil: sql.NullTime{Time: time.Now().Add(lockout), Valid: true},
+	})
+	if err != nil {
+		log.Printf("unable to lock login for %s: %v", key, err)
+	}
+}
+
+func (cfg *apiConfig) clearLoginFailures(email string) {
+	err := cfg.db.ClearLoginThrottles(context.Background(), []string{accountThrottleKey(email)})
+	if err != nil {
+		log.Printf("unable to clear failed logins for %s: %v", email, err)
+	}
+}
+
+func (cfg *apiConfig) handlerUnlockUser(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+	user, err := cfg.db.GetUserById(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusNotFound, "Unable to get user by ID: "+userId.String(), err)
+		return
+	}
+
+	keys := []string{accountThrottleKey(user.Email)}
+	if ip := r.URL.Query().Get("ip"); ip != "" {
+		keys = append(keys, "ip:"+ip)
+	}
+	err = cfg.db.ClearLoginThrottles(context.Background(), keys)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to unlock user", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
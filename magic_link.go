@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/jpheneger/chirpy/internal/mailer"
+)
+
+const magicLinkTTL = 15 * time.Minute
+
+func (cfg *apiConfig) handlerMagicLinkRequest(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	if !strings.Contains(reqBody.Email, "@") {
+		respondWithError(w, http.StatusBadRequest, "a valid email address is required", nil)
+		return
+	}
+	if !cfg.throttleMail(w, r, reqBody.Email) {
+		return
+	}
+
+	// Links are sent whether or not the address has an account, which both
+	// keeps registered addresses private and lets new users sign up.
+	token, err := cfg.keyring.MakeMagicLinkToken(reqBody.Email, magicLinkTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to create sign in link", err)
+		return
+	}
+
+	link := cfg.publicURL + "/api/login/magic-link/callback?token=" + url.QueryEscape(token)
+	cfg.sendMail(mailer.Message{
+		To:      reqBody.Email,
+		Subject: "Your Chirpy sign in link",
+		Body: fmt.Sprintf("Follow this link within the next 15 minutes to sign in to Chirpy:\n\n%s\n\n"+
+			"The link works once. If you didn't ask for it, you can ignore this email.\n", link),
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// handlerMagicLinkCallback exchanges a magic link for the same response as
+// handlerLogin, creating an account without a password if the address has
+// none.
+func (cfg *apiConfig) handlerMagicLinkCallback(w http.ResponseWriter, r *http.Request) {
+	tokenId, email, expiresAt, err := cfg.keyring.ValidateMagicLinkToken(r.URL.Query().Get("token"))
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired sign in link", err)
+		return
+	}
+
+	used, err := cfg.db.UseMagicLink(context.Background(), database.UseMagicLinkParams{
+		TokenID:   tokenId,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to use sign in link", err)
+		return
+	}
+	if used == 0 {
+		respondWithError(w, http.StatusUnauthorized, "sign in link has already been used", nil)
+		return
+	}
+	if err := cfg.db.DeleteExpiredMagicLinks(context.Background()); err != nil {
+		log.Printf("unable to delete expired magic links: %v", err)
+	}
+
+	user, err := cfg.db.GetUserByEmail(context.Background(), email)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = cfg.db.CreatePasswordlessUser(context.Background(), email)
+	} else if err == nil {
+		user, err = cfg.claimUnverifiedAccount(user)
+	}
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to sign in", err)
+		return
+	}
+
+	mfaEnabled, err := cfg.mfaEnabled(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch two-factor settings from db", err)
+		return
+	}
+	if mfaEnabled {
+		cfg.respondWithMFAChallenge(w, user.ID)
+		return
+	}
+
+	cfg.clearLoginFailures(user.Email)
+	cfg.respondWithSession(w, r, user)
+}
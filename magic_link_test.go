@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+var loginThrottleColumns = []string{"key", "failures", "last_failure_at", "locked_until"}
+
+func TestMailRequestsAreThrottled(t *testing.T) {
+	endpoints := map[string]func(*apiConfig) http.HandlerFunc{
+		"magic link":     func(cfg *apiConfig) http.HandlerFunc { return cfg.handlerMagicLinkRequest },
+		"password reset": func(cfg *apiConfig) http.HandlerFunc { return cfg.handlerPasswordResetRequest },
+	}
+	for name, handler := range endpoints {
+		t.Run(name, func(t *testing.T) {
+			cfg, mock, _ := newMockConfig(t)
+			mock.ExpectQuery("GetLoginThrottles").WillReturnRows(sqlmock.NewRows(loginThrottleColumns).
+				AddRow("mail:victim@example.com", 4, time.Now(), time.Now().Add(10*time.Minute)))
+
+			req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(`{"email": "Victim@example.com"}`))
+			rec := httptest.NewRecorder()
+			handler(cfg)(rec, req)
+
+			if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+				t.Errorf("got %d with Retry-After %q, want 429 with Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestMailRequestIsCounted(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	cfg.mailer = discardMailer{}
+	mock.ExpectQuery("GetLoginThrottles").WillReturnRows(sqlmock.NewRows(loginThrottleColumns))
+	for _, key := range []string{"mail:victim@example.com", "mail-ip:192.0.2.1"} {
+		mock.ExpectQuery("RecordLoginFailure").WithArgs(key).WillReturnRows(sqlmock.NewRows(loginThrottleColumns).
+			AddRow(key, 1, time.Now(), nil))
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/login/magic-link", strings.NewReader(`{"email": "Victim@example.com"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerMagicLinkRequest(rec, req)
+	if rec.Code != http.StatusAccepted {
+		t.Errorf("got %d: %s", rec.Code, rec.Body)
+	}
+}
@@ -1,23 +1,51 @@
 package main
 
 import (
+	"context"
 	"database/sql"
 	"log"
 	"net/http"
+	"net/smtp"
 	"os"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/jpheneger/chirpy/internal/auth"
 	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/jpheneger/chirpy/internal/mailer"
+	"github.com/jpheneger/chirpy/internal/oidc"
 	_ "github.com/lib/pq"
 )
 
 type apiConfig struct {
 	fileserverHits atomic.Int32
 	db             database.Queries
-	platform       string
-	signingSecret  string
+	keyring        *auth.Keyring
 	polkaKey       string
+	tokenHashKey   []byte
+	mailer         mailer.Mailer
+	publicURL      string
+	mfaKey         []byte
+	passwordHasher auth.PasswordHasher
+	oidcProvider   *oidc.Provider
+	// requireEmailVerification blocks posting chirps until the author's
+	// email address is verified.
+	requireEmailVerification bool
+	// chirpEditWindow is how long authors may edit a chirp after posting
+	// it; Chirpy Red members get chirpyRedEditWindow.
+	chirpEditWindow     time.Duration
+	chirpyRedEditWindow time.Duration
+	// chirpRetention is how long deleted chirps can be restored before
+	// they are purged.
+	chirpRetention time.Duration
+	// trendingWindow is how far back the trending aggregator looks, and
+	// trendingHalfLife how quickly a hashtag use loses weight within it.
+	trendingWindow   time.Duration
+	trendingHalfLife time.Duration
+	trending         atomic.Pointer[trendingHashtags]
 }
 
 func main() {
@@ -26,45 +54,210 @@ func main() {
 
 	godotenv.Load()
 	dbURL := os.Getenv("DB_URL")
-	platform := os.Getenv("PLATFORM")
 	signingSecret := os.Getenv("SIGNING_SECRET")
 	polkaKey := os.Getenv("POLKA_KEY")
+	tokenHashKey := os.Getenv("REFRESH_TOKEN_SECRET")
+	if tokenHashKey == "" {
+		log.Fatal("REFRESH_TOKEN_SECRET is not set, refusing to hash tokens without a key")
+	}
+	mfaKey := os.Getenv("MFA_ENCRYPTION_KEY")
+	if mfaKey == "" {
+		log.Fatal("MFA_ENCRYPTION_KEY is not set, refusing to encrypt two-factor secrets with a default key")
+	}
+	publicURL := strings.TrimSuffix(os.Getenv("PUBLIC_URL"), "/")
+	if publicURL == "" {
+		publicURL = "http://localhost:" + port
+	}
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
 		log.Fatal("unable to connect to database")
 	}
 	dbqueries := database.New(db)
 
+	hmacKeys, err := auth.ParseSigningKeys(os.Getenv("SIGNING_KEYS"))
+	if err != nil {
+		log.Fatalf("unable to load signing keys: %v", err)
+	}
+	// A plain secret is taken literally, so one from before key rotation
+	// keeps working. Next to SIGNING_KEYS it only verifies older tokens.
+	if signingSecret != "" {
+		hmacKeys = append(hmacKeys, auth.SecretSigningKey(signingSecret))
+	}
+	privateKeys, err := auth.LoadPrivateKeys(os.Getenv("SIGNING_PRIVATE_KEYS"))
+	if err != nil {
+		log.Fatalf("unable to load signing private keys: %v", err)
+	}
+	// Asymmetric keys take precedence so that other services can verify
+	// tokens from the published JWKS.
+	signingKeys := append(privateKeys, hmacKeys...)
+	if len(signingKeys) == 0 {
+		log.Fatal("no signing keys configured, set SIGNING_SECRET, SIGNING_KEYS or SIGNING_PRIVATE_KEYS")
+	}
+	keyring, err := auth.NewKeyring(signingKeys[0], signingKeys[1:]...)
+	if err != nil {
+		log.Fatalf("unable to load signing keys: %v", err)
+	}
+
+	mailFrom := os.Getenv("MAIL_FROM")
+	if mailFrom == "" {
+		mailFrom = "chirpy@localhost"
+	}
+	var mail mailer.Mailer
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		var smtpAuth smtp.Auth
+		if username := os.Getenv("SMTP_USERNAME"); username != "" {
+			host, _, _ := strings.Cut(os.Getenv("SMTP_ADDR"), ":")
+			smtpAuth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+		}
+		mail = mailer.SMTPMailer{Addr: os.Getenv("SMTP_ADDR"), From: mailFrom, Auth: smtpAuth}
+	case "dir":
+		mailDir := os.Getenv("MAIL_DIR")
+		if mailDir == "" {
+			mailDir = "mail"
+		}
+		mail = mailer.DirMailer{Dir: mailDir, From: mailFrom}
+	case "":
+		// There is no default, so that a deploy that forgets to configure
+		// mail doesn't quietly write reset links to disk.
+		log.Fatal("MAILER is not set, expected smtp or dir")
+	default:
+		log.Fatalf("unknown MAILER %q, expected smtp or dir", os.Getenv("MAILER"))
+	}
+
+	argon2Params := auth.DefaultArgon2idParams
+	argon2Params.Memory = uint32(envUint("ARGON2_MEMORY_KIB", uint64(argon2Params.Memory), 32))
+	argon2Params.Iterations = uint32(envUint("ARGON2_ITERATIONS", uint64(argon2Params.Iterations), 32))
+	argon2Params.Parallelism = uint8(envUint("ARGON2_PARALLELISM", uint64(argon2Params.Parallelism), 8))
+
+	// ADMIN_EMAIL bootstraps the first admin, who can then grant roles
+	// through the API.
+	if adminEmail := os.Getenv("ADMIN_EMAIL"); adminEmail != "" {
+		granted, err := dbqueries.GrantRoleByEmail(context.Background(), database.GrantRoleByEmailParams{
+			Email: adminEmail,
+			Role:  auth.RoleAdmin,
+		})
+		if err != nil {
+			log.Fatalf("unable to grant admin role to %s: %v", adminEmail, err)
+		} else if granted > 0 {
+			log.Printf("granted admin role to %s", adminEmail)
+		} else {
+			log.Printf("warning: ADMIN_EMAIL granted nothing: %s has no account with a verified address, or is already an admin", adminEmail)
+		}
+	}
+
+	var oidcProvider *oidc.Provider
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		oidcProvider = &oidc.Provider{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  publicURL + "/api/auth/oidc/callback",
+			HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		}
+	}
+
 	apiCfg := apiConfig{
 		fileserverHits: atomic.Int32{},
 		db:             *dbqueries,
-		platform:       platform,
-		signingSecret:  signingSecret,
+		keyring:        keyring,
 		polkaKey:       polkaKey,
+		tokenHashKey:   []byte(tokenHashKey),
+		mailer:         mail,
+		publicURL:      publicURL,
+		mfaKey:         auth.EncryptionKey(mfaKey),
+		passwordHasher: auth.NewArgon2idHasher(argon2Params),
+		oidcProvider:   oidcProvider,
+
+		requireEmailVerification: os.Getenv("REQUIRE_EMAIL_VERIFICATION") == "true",
+		chirpEditWindow:          envDuration("CHIRP_EDIT_WINDOW", 15*time.Minute),
+		chirpyRedEditWindow:      envDuration("CHIRPY_RED_EDIT_WINDOW", 24*time.Hour),
+		chirpRetention:           envDuration("CHIRP_RETENTION", 30*24*time.Hour),
+		trendingWindow:           envDuration("TRENDING_WINDOW", 24*time.Hour),
+		trendingHalfLife:         envDuration("TRENDING_HALF_LIFE", 6*time.Hour),
 	}
+	go apiCfg.purgeDeletedChirps(time.Hour)
+	go apiCfg.aggregateTrendingHashtags(5 * time.Minute)
 
 	mux := http.NewServeMux()
 	fsHandler := apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(filepathRoot))))
 	mux.Handle("/app/", fsHandler)
 
 	mux.HandleFunc("GET /api/healthz", handlerReadiness)
-	mux.HandleFunc("GET /admin/metrics", apiCfg.handlerMetrics)
+	mux.HandleFunc("GET /.well-known/jwks.json", apiCfg.handlerJWKS)
 
-	mux.HandleFunc("POST /api/chirps", apiCfg.handlerChirps)
-	mux.HandleFunc("GET /api/chirps", apiCfg.handlerAllChirps)
-	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.handlerChirpById)
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.handlerDeleteChirp)
+	mux.HandleFunc("POST /api/chirps", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerChirps))
+	mux.HandleFunc("GET /api/chirps", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerAllChirps))
+	mux.HandleFunc("GET /api/chirps/search", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerSearchChirps))
+	mux.HandleFunc("GET /api/chirps/trash", apiCfg.middlewareAuth(auth.ScopeChirpsRead, apiCfg.handlerChirpTrash))
+	mux.HandleFunc("GET /api/chirps/{chirpID}", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerChirpById))
+	mux.HandleFunc("PUT /api/chirps/{chirpID}", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerEditChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/revisions", apiCfg.handlerChirpRevisions)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerDeleteChirp))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/replies", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerCreateReply))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/thread", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerChirpThread))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/rechirp", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerUndoRechirp))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerLikeChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/like", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerUnlikeChirp))
+	mux.HandleFunc("GET /api/chirps/{chirpID}/likes", apiCfg.handlerChirpLikes)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerBookmarkChirp))
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerDeleteBookmark))
+	mux.HandleFunc("GET /api/bookmarks", apiCfg.middlewareAuth(auth.ScopeChirpsRead, apiCfg.handlerListBookmarks))
+	mux.HandleFunc("GET /api/hashtags/{tag}/chirps", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerHashtagChirps))
+	mux.HandleFunc("GET /api/trending", apiCfg.handlerTrending)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", apiCfg.middlewareAuth(auth.ScopeChirpsWrite, apiCfg.handlerRestoreChirp))
 
 	mux.HandleFunc("POST /api/users", apiCfg.handlerUsers)
-	mux.HandleFunc("PUT /api/users", apiCfg.handlerUpdateUser)
+	mux.HandleFunc("PUT /api/users", apiCfg.middlewareAuth(auth.ScopeProfileWrite, apiCfg.handlerUpdateUser))
+	mux.HandleFunc("GET /api/users/{userID}/likes", apiCfg.middlewareOptionalAuth(auth.ScopeChirpsRead, apiCfg.handlerUserLikes))
 
 	mux.HandleFunc("POST /api/login", apiCfg.handlerLogin)
+	mux.HandleFunc("POST /api/login/mfa", apiCfg.handlerLoginMFA)
+	mux.HandleFunc("POST /api/login/magic-link", apiCfg.handlerMagicLinkRequest)
+	mux.HandleFunc("GET /api/login/magic-link/callback", apiCfg.handlerMagicLinkCallback)
+	mux.HandleFunc("GET /api/auth/oidc/login", apiCfg.handlerOIDCLogin)
+	mux.HandleFunc("GET /api/auth/oidc/callback", apiCfg.handlerOIDCCallback)
 	mux.HandleFunc("POST /api/refresh", apiCfg.handlerRefresh)
 	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
 
+	mux.HandleFunc("GET /api/verify-email", apiCfg.handlerVerifyEmail)
+	mux.HandleFunc("POST /api/verify-email/resend", apiCfg.middlewareSession(apiCfg.handlerResendVerificationEmail))
+
+	mux.HandleFunc("POST /api/password-reset/request", apiCfg.handlerPasswordResetRequest)
+	mux.HandleFunc("POST /api/password-reset/confirm", apiCfg.handlerPasswordResetConfirm)
+
+	mux.HandleFunc("POST /api/mfa/totp/enroll", apiCfg.middlewareSession(apiCfg.handlerEnrollTOTP))
+	mux.HandleFunc("POST /api/mfa/totp/confirm", apiCfg.middlewareSession(apiCfg.handlerConfirmTOTP))
+	mux.HandleFunc("POST /api/mfa/totp/disable", apiCfg.middlewareSession(apiCfg.handlerDisableTOTP))
+
+	mux.HandleFunc("GET /api/sessions", apiCfg.middlewareSession(apiCfg.handlerListSessions))
+	mux.HandleFunc("DELETE /api/sessions/{id}", apiCfg.middlewareSession(apiCfg.handlerRevokeSession))
+	mux.HandleFunc("POST /api/sessions/revoke-all", apiCfg.middlewareSession(apiCfg.handlerRevokeAllSessions))
+
+	mux.HandleFunc("POST /api/tokens", apiCfg.middlewareSession(apiCfg.handlerCreateToken))
+	mux.HandleFunc("GET /api/tokens", apiCfg.middlewareSession(apiCfg.handlerListTokens))
+	mux.HandleFunc("DELETE /api/tokens/{tokenID}", apiCfg.middlewareSession(apiCfg.handlerRevokeToken))
+
+	mux.HandleFunc("POST /api/oauth/clients", apiCfg.middlewareSession(apiCfg.handlerCreateOAuthClient))
+	mux.HandleFunc("GET /api/oauth/clients", apiCfg.middlewareSession(apiCfg.handlerListOAuthClients))
+	mux.HandleFunc("DELETE /api/oauth/clients/{clientID}", apiCfg.middlewareSession(apiCfg.handlerDeleteOAuthClient))
+	mux.HandleFunc("GET /oauth/authorize", apiCfg.handlerOAuthAuthorize)
+	mux.HandleFunc("POST /oauth/authorize", apiCfg.handlerOAuthConsent)
+	mux.HandleFunc("GET /oauth/clients/{clientID}", apiCfg.handlerOAuthClientInfo)
+	mux.HandleFunc("POST /oauth/authorize/login", apiCfg.handlerOAuthConsentLogin)
+	mux.HandleFunc("POST /oauth/authorize/login/mfa", apiCfg.handlerOAuthConsentLoginMFA)
+	mux.HandleFunc("POST /oauth/token", apiCfg.handlerOAuthToken)
+	mux.HandleFunc("POST /oauth/revoke", apiCfg.handlerOAuthRevoke)
+	mux.HandleFunc("POST /oauth/introspect", apiCfg.handlerOAuthIntrospect)
+
 	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.handlerUpgrade)
 
-	mux.HandleFunc("POST /admin/reset", apiCfg.handlerReset)
+	mux.HandleFunc("GET /admin/metrics", apiCfg.middlewarePermission(auth.PermissionMetricsRead, apiCfg.handlerMetrics))
+	mux.HandleFunc("POST /admin/reset", apiCfg.middlewarePermission(auth.PermissionSystemReset, apiCfg.handlerReset))
+	mux.HandleFunc("POST /admin/users/{userID}/unlock", apiCfg.middlewarePermission(auth.PermissionUsersUnlock, apiCfg.handlerUnlockUser))
+	mux.HandleFunc("GET /admin/users/{userID}/roles", apiCfg.middlewarePermission(auth.PermissionRolesManage, apiCfg.handlerListUserRoles))
+	mux.HandleFunc("POST /admin/users/{userID}/roles", apiCfg.middlewarePermission(auth.PermissionRolesManage, apiCfg.handlerGrantRole))
+	mux.HandleFunc("DELETE /admin/users/{userID}/roles/{role}", apiCfg.middlewarePermission(auth.PermissionRolesManage, apiCfg.handlerRevokeRole))
 
 	srv := &http.Server{
 		Addr:    ":" + port,
@@ -74,3 +267,27 @@ func main() {
 	log.Printf("Serving files from %s on port: %s\n", filepathRoot, port)
 	log.Fatal(srv.ListenAndServe())
 }
+
+func envUint(name string, fallback uint64, bitSize int) uint64 {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := strconv.ParseUint(value, 10, bitSize)
+	if err != nil || parsed == 0 {
+		log.Fatalf("%s must be a positive integer, got %q", name, value)
+	}
+	return parsed
+}
+
+func envDuration(name string, fallback time.Duration) time.Duration {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		log.Fatalf("%s must be a duration such as 15m, got %q", name, value)
+	}
+	return parsed
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+const (
+	mfaChallengeTTL   = 5 * time.Minute
+	recoveryCodeCount = 10
+)
+
+var errInvalidSecondFactor = errors.New("invalid two-factor code")
+
+type secondFactor struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+func (cfg *apiConfig) mfaEnabled(userId uuid.UUID) (bool, error) {
+	credential, err := cfg.db.GetTotpCredential(context.Background(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return credential.ConfirmedAt.Valid, nil
+}
+
+func (cfg *apiConfig) respondWithMFAChallenge(w http.ResponseWriter, userId uuid.UUID) {
+	type resp struct {
+		MFARequired bool   `json:"mfa_required"`
+		MFAToken    string `json:"mfa_token"`
+	}
+
+	mfaToken, err := cfg.keyring.MakeMFAChallengeToken(userId, mfaChallengeTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to create two-factor challenge", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp{
+		MFARequired: true,
+		MFAToken:    mfaToken,
+	})
+}
+
+func (cfg *apiConfig) handlerLoginMFA(w http.ResponseWriter, r *http.Request) {
+	cfg.mfaLogin(w, r, cfg.respondWithSession)
+}
+
+// mfaLogin checks the second factor for a challenge from passwordLogin and
+// completes the login.
+func (cfg *apiConfig) mfaLogin(w http.ResponseWriter, r *http.Request, complete loginCompleter) {
+	type req struct {
+		MFAToken string `json:"mfa_token"`
+		secondFactor
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+
+	userId, err := cfg.keyring.ValidateMFAChallengeToken(reqBody.MFAToken)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "invalid or expired two-factor challenge", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
+		return
+	}
+
+	// Guessing codes counts against the same lockout as guessing passwords.
+	if !cfg.checkLoginThrottle(w, accountThrottleKey(user.Email), ipThrottleKey(r)) {
+		return
+	}
+	err = cfg.verifySecondFactor(userId, reqBody.secondFactor)
+	if errors.Is(err, errInvalidSecondFactor) {
+		cfg.recordLoginFailure(r, user.Email)
+		respondWithError(w, http.StatusUnauthorized, "Login failed", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to verify two-factor code", err)
+		return
+	}
+	cfg.clearLoginFailures(user.Email)
+
+	complete(w, r, user)
+}
+
+// verifySecondFactor accepts either a current TOTP code, which can only be
+// used once, or an unused recovery code.
+func (cfg *apiConfig) verifySecondFactor(userId uuid.UUID, factor secondFactor) error {
+	if factor.RecoveryCode != "" {
+		used, err := cfg.db.UseRecoveryCode(context.Background(), database.UseRecoveryCodeParams{
+			CodeHash: auth.HashToken(cfg.tokenHashKey, auth.NormalizeRecoveryCode(factor.RecoveryCode)),
+			UserID:   userId,
+		})
+		if err != nil {
+			return err
+		}
+		if used == 0 {
+			return errInvalidSecondFactor
+		}
+		return nil
+	}
+
+	credential, err := cfg.db.GetTotpCredential(context.Background(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errInvalidSecondFactor
+	} else if err != nil {
+		return err
+	}
+	return cfg.useTOTPCode(credential, factor.Code)
+}
+
+func (cfg *apiConfig) useTOTPCode(credential database.TotpCredential, code string) error {
+	secret, err := auth.DecryptSecret(cfg.mfaKey, credential.EncryptedSecret, credential.UserID[:])
+	if err != nil {
+		return err
+	}
+	step, ok := auth.ValidateTOTP(secret, code, time.Now())
+	if !ok {
+		return errInvalidSecondFactor
+	}
+
+	// Only move forward, so a code observed in transit can't be replayed
+	// within its validity window.
+	used, err := cfg.db.UseTotpStep(context.Background(), database.UseTotpStepParams{
+		UserID:       credential.UserID,
+		LastUsedStep: step,
+	})
+	if err != nil {
+		return err
+	}
+	if used == 0 {
+		return errInvalidSecondFactor
+	}
+	return nil
+}
+
+func (cfg *apiConfig) handlerEnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		Secret     string `json:"secret"`
+		OtpauthURI string `json:"otpauth_uri"`
+	}
+
+	userId := principalFrom(r.Context()).UserID
+	user, err := cfg.db.GetUserById(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to generate two-factor secret", err)
+		return
+	}
+	encrypted, err := auth.EncryptSecret(cfg.mfaKey, secret, user.ID[:])
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to encrypt two-factor secret", err)
+		return
+	}
+
+	created, err := cfg.db.CreatePendingTotpCredential(context.Background(), database.CreatePendingTotpCredentialParams{
+		UserID:          user.ID,
+		EncryptedSecret: encrypted,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to store two-factor secret", err)
+		return
+	}
+	if created == 0 {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled", nil)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp{
+		Secret:     secret,
+		OtpauthURI: auth.TOTPURI("Chirpy", user.Email, secret),
+	})
+}
+
+func (cfg *apiConfig) handlerConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Code string `json:"code"`
+	}
+	type resp struct {
+		RecoveryCodes []string `json:"recovery_codes"`
+	}
+
+	userId := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+
+	credential, err := cfg.db.GetTotpCredential(context.Background(), userId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "two-factor enrollment not started", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch two-factor settings from db", err)
+		return
+	}
+	if credential.ConfirmedAt.Valid {
+		respondWithError(w, http.StatusConflict, "two-factor authentication is already enabled", nil)
+		return
+	}
+
+	err = cfg.useTOTPCode(credential, reqBody.Code)
+	if errors.Is(err, errInvalidSecondFactor) {
+		respondWithError(w, http.StatusBadRequest, "invalid two-factor code", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to verify two-factor code", err)
+		return
+	}
+
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to generate recovery codes", err)
+		return
+	}
+	hashes := make([]string, 0, len(codes))
+	for _, code := range codes {
+		hashes = append(hashes, auth.HashToken(cfg.tokenHashKey, auth.NormalizeRecoveryCode(code)))
+	}
+	err = cfg.db.DeleteRecoveryCodes(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to replace recovery codes", err)
+		return
+	}
+	err = cfg.db.CreateRecoveryCodes(context.Background(), database.CreateRecoveryCodesParams{
+		CodeHashes: hashes,
+		UserID:     userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to store recovery codes", err)
+		return
+	}
+
+	err = cfg.db.ConfirmTotpCredential(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to enable two-factor authentication", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp{
+		RecoveryCodes: codes,
+	})
+}
+
+func (cfg *apiConfig) handlerDisableTOTP(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := secondFactor{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+
+	user, err := cfg.db.GetUserById(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
+		return
+	}
+
+	// A stolen access token alone must not be enough to turn 2FA off, so
+	// guessing the code counts against the login lockout.
+	if !cfg.checkLoginThrottle(w, accountThrottleKey(user.Email), ipThrottleKey(r)) {
+		return
+	}
+	err = cfg.verifySecondFactor(userId, reqBody)
+	if errors.Is(err, errInvalidSecondFactor) {
+		cfg.recordLoginFailure(r, user.Email)
+		respondWithError(w, http.StatusForbidden, "invalid two-factor code", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to verify two-factor code", err)
+		return
+	}
+
+	err = cfg.db.DeleteTotpCredential(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to disable two-factor authentication", err)
+		return
+	}
+	err = cfg.db.DeleteRecoveryCodes(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete recovery codes", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func disableTOTPRequest(userId uuid.UUID) *http.Request {
+	req := httptest.NewRequest(http.MethodDelete, "/api/mfa/totp", strings.NewReader(`{"recovery_code": "guess"}`))
+	return asUser(req, userId)
+}
+
+func TestDisableTOTPIsThrottled(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	user := unverifiedUser("user@example.com")
+	mock.ExpectQuery("GetUserById").WithArgs(user.ID).WillReturnRows(userRow(user))
+	mock.ExpectQuery("GetLoginThrottles").WillReturnRows(sqlmock.NewRows(loginThrottleColumns).
+		AddRow("email:user@example.com", 6, time.Now(), time.Now().Add(time.Minute)))
+
+	rec := httptest.NewRecorder()
+	cfg.handlerDisableTOTP(rec, disableTOTPRequest(user.ID))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("got %d: %s, want 429", rec.Code, rec.Body)
+	}
+}
+
+func TestDisableTOTPCountsWrongCodes(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	user := unverifiedUser("user@example.com")
+	mock.ExpectQuery("GetUserById").WithArgs(user.ID).WillReturnRows(userRow(user))
+	mock.ExpectQuery("GetLoginThrottles").WillReturnRows(sqlmock.NewRows(loginThrottleColumns))
+	mock.ExpectExec("UseRecoveryCode").WillReturnResult(sqlmock.NewResult(0, 0))
+	for _, key := range []string{"email:user@example.com", "ip:192.0.2.1"} {
+		mock.ExpectQuery("RecordLoginFailure").WithArgs(key).WillReturnRows(sqlmock.NewRows(loginThrottleColumns).
+			AddRow(key, 1, time.Now(), nil))
+	}
+
+	rec := httptest.NewRecorder()
+	cfg.handlerDisableTOTP(rec, disableTOTPRequest(user.ID))
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("got %d: %s, want 403", rec.Code, rec.Body)
+	}
+}
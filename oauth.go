@@ -0,0 +1,512 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+const (
+	oauthConsentTTL      = 10 * time.Minute
+	oauthCodeTTL         = 10 * time.Minute
+	oauthAccessTokenTTL  = 1 * time.Hour
+	oauthRefreshTokenTTL = 60 * 24 * time.Hour
+)
+
+// authorizeRequest holds the parameters of an authorization request. They
+// arrive as a query string on GET /oauth/authorize and are posted back as
+// JSON by the consent page.
+type authorizeRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// authorizeClient looks up the requesting client and resolves its redirect
+// URI. An error here must be shown to the user rather than redirected, since
+// the redirect URI can't be trusted.
+func (cfg *apiConfig) authorizeClient(ar *authorizeRequest) (database.OauthClient, error) {
+	client, err := cfg.db.GetOauthClient(context.Background(), ar.ClientID)
+	if err != nil {
+		return database.OauthClient{}, errors.New("unknown client")
+	}
+	if ar.RedirectURI == "" && len(client.RedirectUris) == 1 {
+		ar.RedirectURI = client.RedirectUris[0]
+	}
+	if !slices.Contains(client.RedirectUris, ar.RedirectURI) {
+		return database.OauthClient{}, errors.New("redirect_uri is not registered for this client")
+	}
+	return client, nil
+}
+
+// scopes checks the rest of the request, returning an OAuth error code and
+// description to redirect back to the client on failure.
+func (ar authorizeRequest) scopes() ([]string, string, string) {
+	if ar.ResponseType != "code" {
+		return nil, "unsupported_response_type", "only the code response type is supported"
+	}
+	if ar.CodeChallenge == "" || ar.CodeChallengeMethod != "S256" {
+		return nil, "invalid_request", "PKCE with code_challenge_method S256 is required"
+	}
+	scopes, err := auth.ParseScope(ar.Scope)
+	if err != nil {
+		return nil, "invalid_scope", err.Error()
+	}
+	if len(scopes) == 0 {
+		return nil, "invalid_scope", "at least one scope is required"
+	}
+	return scopes, "", ""
+}
+
+// redirect builds the URI the user agent is sent back to, keeping any query
+// the client registered.
+func (ar authorizeRequest) redirect(params url.Values) string {
+	u, _ := url.Parse(ar.RedirectURI)
+	query := u.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	if ar.State != "" {
+		query.Set("state", ar.State)
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+func (ar authorizeRequest) redirectError(code, description string) string {
+	return ar.redirect(url.Values{
+		"error":             {code},
+		"error_description": {description},
+	})
+}
+
+// handlerOAuthAuthorize validates an authorization request and sends the user
+// on to the consent page, which signs them in and posts their decision to
+// handlerOAuthConsent.
+func (cfg *apiConfig) handlerOAuthAuthorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	ar := authorizeRequest{
+		ResponseType:        query.Get("response_type"),
+		ClientID:            query.Get("client_id"),
+		RedirectURI:         query.Get("redirect_uri"),
+		Scope:               query.Get("scope"),
+		State:               query.Get("state"),
+		CodeChallenge:       query.Get("code_challenge"),
+		CodeChallengeMethod: query.Get("code_challenge_method"),
+	}
+
+	resolved := ar
+	if _, err := cfg.authorizeClient(&resolved); err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	if _, code, description := resolved.scopes(); code != "" {
+		http.Redirect(w, r, resolved.redirectError(code, description), http.StatusFound)
+		return
+	}
+
+	// Only the request itself is passed on, with redirect_uri as the client
+	// sent it, so that the code records whether the token request must repeat
+	// it. The consent page looks the client's name up by client_id, so a link
+	// can't claim to be another app.
+	consent := url.Values{
+		"response_type":         {ar.ResponseType},
+		"client_id":             {ar.ClientID},
+		"redirect_uri":          {ar.RedirectURI},
+		"scope":                 {ar.Scope},
+		"state":                 {ar.State},
+		"code_challenge":        {ar.CodeChallenge},
+		"code_challenge_method": {ar.CodeChallengeMethod},
+	}
+	http.Redirect(w, r, "/app/static/oauth/consent.html?"+consent.Encode(), http.StatusFound)
+}
+
+// handlerOAuthClientInfo returns what the consent page shows about a client.
+func (cfg *apiConfig) handlerOAuthClientInfo(w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		ClientID string `json:"client_id"`
+		Name     string `json:"name"`
+	}
+
+	client, err := cfg.db.GetOauthClient(context.Background(), r.PathValue("clientID"))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "unknown client", nil)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch client from db", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp{ClientID: client.ID, Name: client.Name})
+}
+
+// handlerOAuthConsentLogin signs a user in on the consent page, responding
+// like POST /api/login but with a short lived consent token in place of a
+// session.
+func (cfg *apiConfig) handlerOAuthConsentLogin(w http.ResponseWriter, r *http.Request) {
+	cfg.passwordLogin(w, r, cfg.respondWithConsentToken)
+}
+
+func (cfg *apiConfig) handlerOAuthConsentLoginMFA(w http.ResponseWriter, r *http.Request) {
+	cfg.mfaLogin(w, r, cfg.respondWithConsentToken)
+}
+
+func (cfg *apiConfig) respondWithConsentToken(w http.ResponseWriter, r *http.Request, user database.User) {
+	type resp struct {
+		ConsentToken string `json:"consent_token"`
+	}
+
+	token, err := cfg.keyring.MakeOAuthConsentToken(user.ID, oauthConsentTTL)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to create consent token", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, resp{ConsentToken: token})
+}
+
+func (cfg *apiConfig) handlerOAuthConsent(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		authorizeRequest
+		Approve bool `json:"approve"`
+	}
+	type resp struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+
+	// Only a user signed in with their password, and second factor if
+	// enrolled, may grant access.
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondUnauthorized(w, "", "sign in to answer this request", err)
+		return
+	}
+	userId, err := cfg.keyring.ValidateOAuthConsentToken(token)
+	if err != nil {
+		respondUnauthorized(w, "invalid_token", "invalid or expired consent token", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err = decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	ar := reqBody.authorizeRequest
+	// RFC 6749 4.1.3 only requires redirect_uri at the token endpoint if the
+	// authorization request included it.
+	sentRedirectURI := ar.RedirectURI
+
+	client, err := cfg.authorizeClient(&ar)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	scopes, code, description := ar.scopes()
+	if code != "" {
+		respondWithJSON(w, http.StatusOK, resp{RedirectTo: ar.redirectError(code, description)})
+		return
+	}
+	if !reqBody.Approve {
+		respondWithJSON(w, http.StatusOK, resp{RedirectTo: ar.redirectError("access_denied", "the user denied the request")})
+		return
+	}
+
+	authCode, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to create authorization code", err)
+		return
+	}
+	err = cfg.db.CreateOauthAuthorizationCode(context.Background(), database.CreateOauthAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(cfg.tokenHashKey, authCode),
+		GrantID:       uuid.New(),
+		ClientID:      client.ID,
+		UserID:        userId,
+		RedirectUri:   sentRedirectURI,
+		Scopes:        scopes,
+		CodeChallenge: ar.CodeChallenge,
+		ExpiresAt:     time.Now().Add(oauthCodeTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to store authorization code", err)
+		return
+	}
+
+	respondWithJSON(w, http.StatusOK, resp{RedirectTo: ar.redirect(url.Values{"code": {authCode}})})
+}
+
+func respondWithOAuthError(w http.ResponseWriter, code int, errorCode, description string, err error) {
+	type errorResponse struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description,omitempty"`
+	}
+
+	if err != nil {
+		log.Println(err)
+	}
+	if code == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, code, errorResponse{
+		Error:            errorCode,
+		ErrorDescription: description,
+	})
+}
+
+func (cfg *apiConfig) handlerOAuthToken(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form body", err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(r)
+	if errors.Is(err, errInvalidClient) {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error(), nil)
+		return
+	} else if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		cfg.exchangeAuthorizationCode(w, r, client)
+	case "refresh_token":
+		cfg.exchangeOAuthRefreshToken(w, r, client)
+	default:
+		respondWithOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "", nil)
+	}
+}
+
+func (cfg *apiConfig) exchangeAuthorizationCode(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	codeHash := auth.HashToken(cfg.tokenHashKey, r.PostForm.Get("code"))
+	// Only the client the code was issued to can use it up, so another
+	// client that learns a code can't burn it.
+	code, err := cfg.db.ConsumeOauthAuthorizationCode(context.Background(), database.ConsumeOauthAuthorizationCodeParams{
+		CodeHash:    codeHash,
+		ClientID:    client.ID,
+		RedirectUri: r.PostForm.Get("redirect_uri"),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// A code presented twice may have been intercepted, so revoke
+		// everything issued for it (RFC 6749 4.1.2).
+		used, err := cfg.db.GetOauthAuthorizationCode(context.Background(), codeHash)
+		if err == nil && used.UsedAt.Valid && used.ClientID == client.ID {
+			if err := cfg.db.RevokeOauthGrant(context.Background(), used.GrantID); err != nil {
+				log.Printf("unable to revoke grant %s: %s", used.GrantID, err)
+			}
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "authorization code is invalid or expired", nil)
+		return
+	} else if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "code_verifier does not match the code challenge", nil)
+		return
+	}
+
+	cfg.respondWithOAuthTokens(w, code.GrantID, client.ID, code.UserID, code.Scopes, code.Scopes)
+}
+
+func (cfg *apiConfig) exchangeOAuthRefreshToken(w http.ResponseWriter, r *http.Request, client database.OauthClient) {
+	tokenHash := auth.HashToken(cfg.tokenHashKey, r.PostForm.Get("refresh_token"))
+	token, err := cfg.db.GetOauthToken(context.Background(), tokenHash)
+	if err != nil || token.TokenType != "refresh_token" || token.ClientID != client.ID {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is invalid", err)
+		return
+	}
+
+	// Refresh tokens rotate on every use, and reuse of a rotated one revokes
+	// the grant, as with Chirpy's own refresh tokens.
+	_, err = cfg.db.RotateOauthRefreshToken(context.Background(), tokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		if token.RevokedAt.Valid {
+			if err := cfg.db.RevokeOauthGrant(context.Background(), token.GrantID); err != nil {
+				log.Printf("unable to revoke grant %s: %s", token.GrantID, err)
+			}
+		}
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_grant", "refresh token is revoked or expired", nil)
+		return
+	} else if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	// The client may ask for a subset of the granted scopes for the new
+	// access token; the new refresh token keeps all of them.
+	scopes := token.Scopes
+	if requested := r.PostForm.Get("scope"); requested != "" {
+		scopes, err = auth.ParseScope(requested)
+		if err != nil {
+			respondWithOAuthError(w, http.StatusBadRequest, "invalid_scope", err.Error(), nil)
+			return
+		}
+		for _, scope := range scopes {
+			if !auth.HasScope(token.Scopes, scope) {
+				respondWithOAuthError(w, http.StatusBadRequest, "invalid_scope", "scope "+scope+" was not granted", nil)
+				return
+			}
+		}
+	}
+
+	cfg.respondWithOAuthTokens(w, token.GrantID, client.ID, token.UserID, scopes, token.Scopes)
+}
+
+func (cfg *apiConfig) respondWithOAuthTokens(w http.ResponseWriter, grantID uuid.UUID, clientID string, userID uuid.UUID, scopes, refreshScopes []string) {
+	type resp struct {
+		AccessToken  string `json:"access_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"`
+		RefreshToken string `json:"refresh_token"`
+		Scope        string `json:"scope"`
+	}
+
+	accessToken, err := cfg.createOAuthToken("access_token", grantID, clientID, userID, scopes, oauthAccessTokenTTL)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+	refreshToken, err := cfg.createOAuthToken("refresh_token", grantID, clientID, userID, refreshScopes, oauthRefreshTokenTTL)
+	if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, http.StatusOK, resp{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(oauthAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		Scope:        strings.Join(scopes, " "),
+	})
+}
+
+func (cfg *apiConfig) createOAuthToken(tokenType string, grantID uuid.UUID, clientID string, userID uuid.UUID, scopes []string, ttl time.Duration) (string, error) {
+	makeToken := auth.MakeOAuthAccessToken
+	if tokenType == "refresh_token" {
+		makeToken = auth.MakeOAuthRefreshToken
+	}
+	token, err := makeToken()
+	if err != nil {
+		return "", err
+	}
+	err = cfg.db.CreateOauthToken(context.Background(), database.CreateOauthTokenParams{
+		TokenHash: auth.HashToken(cfg.tokenHashKey, token),
+		TokenType: tokenType,
+		GrantID:   grantID,
+		ClientID:  clientID,
+		UserID:    userID,
+		Scopes:    scopes,
+		ExpiresAt: time.Now().Add(ttl),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// handlerOAuthRevoke implements RFC 7009. Revoking a refresh token revokes
+// every token of its grant. Unknown tokens, and tokens belonging to other
+// clients, are ignored so the response reveals nothing about them.
+func (cfg *apiConfig) handlerOAuthRevoke(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form body", err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(r)
+	if errors.Is(err, errInvalidClient) {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error(), nil)
+		return
+	} else if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	tokenHash := auth.HashToken(cfg.tokenHashKey, r.PostForm.Get("token"))
+	token, err := cfg.db.GetOauthToken(context.Background(), tokenHash)
+	if err != nil || token.ClientID != client.ID {
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if token.TokenType == "refresh_token" {
+		err = cfg.db.RevokeOauthGrant(context.Background(), token.GrantID)
+	} else {
+		err = cfg.db.RevokeOauthToken(context.Background(), tokenHash)
+	}
+	if err != nil {
+		respondWithOAuthError(w, http.StatusServiceUnavailable, "temporarily_unavailable", "", err)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handlerOAuthIntrospect implements RFC 7662 for a client's own tokens.
+func (cfg *apiConfig) handlerOAuthIntrospect(w http.ResponseWriter, r *http.Request) {
+	type resp struct {
+		Active    bool   `json:"active"`
+		Scope     string `json:"scope,omitempty"`
+		ClientID  string `json:"client_id,omitempty"`
+		Sub       string `json:"sub,omitempty"`
+		TokenType string `json:"token_type,omitempty"`
+		Exp       int64  `json:"exp,omitempty"`
+		Iat       int64  `json:"iat,omitempty"`
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		respondWithOAuthError(w, http.StatusBadRequest, "invalid_request", "couldn't parse form body", err)
+		return
+	}
+
+	client, err := cfg.authenticateClient(r)
+	if errors.Is(err, errInvalidClient) {
+		respondWithOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error(), nil)
+		return
+	} else if err != nil {
+		respondWithOAuthError(w, http.StatusInternalServerError, "server_error", "", err)
+		return
+	}
+
+	token, err := cfg.db.GetActiveOauthToken(context.Background(), auth.HashToken(cfg.tokenHashKey, r.PostForm.Get("token")))
+	if err != nil || token.ClientID != client.ID {
+		respondWithJSON(w, http.StatusOK, resp{Active: false})
+		return
+	}
+
+	responseBody := resp{
+		Active:   true,
+		Scope:    strings.Join(token.Scopes, " "),
+		ClientID: token.ClientID,
+		Sub:      token.UserID.String(),
+		Exp:      token.ExpiresAt.Unix(),
+		Iat:      token.CreatedAt.Unix(),
+	}
+	if token.TokenType == "access_token" {
+		responseBody.TokenType = "Bearer"
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
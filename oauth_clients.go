@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+var errInvalidClient = errors.New("client authentication failed")
+
+type OAuthClient struct {
+	ClientId     string    `json:"client_id"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+	Name         string    `json:"name"`
+	RedirectUris []string  `json:"redirect_uris"`
+	Confidential bool      `json:"confidential"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+func (cfg *apiConfig) handlerCreateOAuthClient(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Name         string   `json:"name"`
+		RedirectUris []string `json:"redirect_uris"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	userId := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	if reqBody.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "client name is required", nil)
+		return
+	}
+	if len(reqBody.RedirectUris) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one redirect uri is required", nil)
+		return
+	}
+	for _, redirectURI := range reqBody.RedirectUris {
+		if err := validateRedirectURI(redirectURI); err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid redirect uri "+redirectURI+": "+err.Error(), err)
+			return
+		}
+	}
+
+	// Public clients, such as mobile and single page apps, can't keep a
+	// secret and rely on PKCE alone.
+	secret := ""
+	secretHash := sql.NullString{}
+	if reqBody.Confidential {
+		secret, err = auth.MakeOAuthClientSecret()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to create client secret", err)
+			return
+		}
+		secretHash = sql.NullString{String: auth.HashToken(cfg.tokenHashKey, secret), Valid: true}
+	}
+
+	client, err := cfg.db.CreateOauthClient(context.Background(), database.CreateOauthClientParams{
+		ID:           uuid.New().String(),
+		SecretHash:   secretHash,
+		Name:         reqBody.Name,
+		RedirectUris: reqBody.RedirectUris,
+		OwnerID:      userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to store client", err)
+		return
+	}
+
+	// Like personal access tokens, the secret is only ever shown once.
+	responseBody := oauthClientFromDB(client)
+	responseBody.ClientSecret = secret
+	respondWithJSON(w, http.StatusCreated, responseBody)
+}
+
+func (cfg *apiConfig) handlerListOAuthClients(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	clients, err := cfg.db.ListOauthClientsForOwner(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get clients from db", err)
+		return
+	}
+
+	responseBody := []OAuthClient{}
+	for _, client := range clients {
+		responseBody = append(responseBody, oauthClientFromDB(client))
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
+
+func (cfg *apiConfig) handlerDeleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	// Deleting a client cascades to every code and token issued to it.
+	deleted, err := cfg.db.DeleteOauthClient(context.Background(), database.DeleteOauthClientParams{
+		ID:      r.PathValue("clientID"),
+		OwnerID: userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to delete client", err)
+		return
+	}
+	if deleted == 0 {
+		respondWithError(w, http.StatusNotFound, "client not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// authenticateClient identifies the OAuth client making a token, revocation
+// or introspection request, from HTTP Basic credentials or the client_id and
+// client_secret form parameters. Confidential clients must present their
+// secret; public clients only their id.
+func (cfg *apiConfig) authenticateClient(r *http.Request) (database.OauthClient, error) {
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 2.3.1 form-encodes the credentials before base64.
+		var err error
+		if clientID, err = url.QueryUnescape(clientID); err != nil {
+			return database.OauthClient{}, errInvalidClient
+		}
+		if secret, err = url.QueryUnescape(secret); err != nil {
+			return database.OauthClient{}, errInvalidClient
+		}
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+	if clientID == "" {
+		return database.OauthClient{}, errInvalidClient
+	}
+
+	client, err := cfg.db.GetOauthClient(context.Background(), clientID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return database.OauthClient{}, errInvalidClient
+	} else if err != nil {
+		return database.OauthClient{}, err
+	}
+
+	if client.SecretHash.Valid {
+		hash := auth.HashToken(cfg.tokenHashKey, secret)
+		if secret == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(client.SecretHash.String)) != 1 {
+			return database.OauthClient{}, errInvalidClient
+		}
+	}
+	return client, nil
+}
+
+// validateRedirectURI requires an absolute https URI without a fragment.
+// Plain http is allowed for loopback addresses so apps can be developed
+// locally.
+func validateRedirectURI(redirectURI string) error {
+	u, err := url.Parse(redirectURI)
+	if err != nil {
+		return err
+	}
+	if u.Fragment != "" {
+		return errors.New("must not contain a fragment")
+	}
+	switch u.Scheme {
+	case "https":
+	case "http":
+		if host := u.Hostname(); host != "localhost" && host != "127.0.0.1" && host != "::1" {
+			return errors.New("http is only allowed for localhost")
+		}
+	default:
+		return errors.New("must use https")
+	}
+	if u.Host == "" {
+		return errors.New("must be absolute")
+	}
+	return nil
+}
+
+func oauthClientFromDB(client database.OauthClient) OAuthClient {
+	return OAuthClient{
+		ClientId:     client.ID,
+		Name:         client.Name,
+		RedirectUris: client.RedirectUris,
+		Confidential: client.SecretHash.Valid,
+		CreatedAt:    client.CreatedAt,
+	}
+}
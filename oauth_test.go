@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+)
+
+var oauthClientColumns = []string{"id", "secret_hash", "name", "redirect_uris", "owner_id", "created_at"}
+
+func TestOAuthAuthorizeDoesNotPassClientName(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	mock.ExpectQuery("GetOauthClient").WithArgs("app").WillReturnRows(sqlmock.NewRows(oauthClientColumns).
+		AddRow("app", nil, "Real App", "{https://app.example/callback}", uuid.New(), time.Now()))
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {"app"},
+		"scope":                 {"chirps:read"},
+		"code_challenge":        {"challenge"},
+		"code_challenge_method": {"S256"},
+		"client_name":           {"Trusted App"},
+	}
+	rec := httptest.NewRecorder()
+	cfg.handlerOAuthAuthorize(rec, httptest.NewRequest(http.MethodGet, "/oauth/authorize?"+query.Encode(), nil))
+
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if rec.Code != http.StatusFound || err != nil || location.Path != "/app/static/oauth/consent.html" {
+		t.Fatalf("got %d redirecting to %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if got := location.Query()["client_name"]; len(got) != 0 {
+		t.Errorf("consent page was sent client_name %q", got)
+	}
+}
+
+func TestOAuthClientInfo(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	mock.ExpectQuery("GetOauthClient").WithArgs("app").WillReturnRows(sqlmock.NewRows(oauthClientColumns).
+		AddRow("app", "secret hash", "Real App", "{https://app.example/callback}", uuid.New(), time.Now()))
+
+	req := httptest.NewRequest(http.MethodGet, "/oauth/clients/app", nil)
+	req.SetPathValue("clientID", "app")
+	rec := httptest.NewRecorder()
+	cfg.handlerOAuthClientInfo(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"client_id":"app","name":"Real App"}` {
+		t.Errorf("got %d: %s", rec.Code, rec.Body)
+	}
+}
+
+func TestOAuthConsentLoginIssuesNoSession(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	hash, err := cfg.passwordHasher.Hash("hunter2")
+	if err != nil {
+		t.Fatalf("Hash failed with error: %v", err)
+	}
+	user := unverifiedUser("user@example.com")
+	user.HashedPassword = sql.NullString{String: hash, Valid: true}
+
+	// There is no CreateRefreshToken: the consent page never gets a session.
+	mock.ExpectQuery("GetLoginThrottles").WillReturnRows(sqlmock.NewRows(loginThrottleColumns))
+	mock.ExpectQuery("GetUserByEmail").WithArgs(user.Email).WillReturnRows(userRow(user))
+	mock.ExpectQuery("GetTotpCredential").WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec("ClearLoginThrottles").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize/login", strings.NewReader(`{"email": "user@example.com", "password": "hunter2"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerOAuthConsentLogin(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	var body map[string]string
+	json.Unmarshal(rec.Body.Bytes(), &body)
+	if len(body) != 1 || body["consent_token"] == "" {
+		t.Fatalf("got %s, want only a consent token", rec.Body)
+	}
+	if userId, err := cfg.keyring.ValidateOAuthConsentToken(body["consent_token"]); err != nil || userId != user.ID {
+		t.Errorf("ValidateOAuthConsentToken = %s, %v; want %s", userId, err, user.ID)
+	}
+	if _, _, err := cfg.keyring.ValidateAccessToken(body["consent_token"]); err == nil {
+		t.Error("consent token was accepted as an access token")
+	}
+}
+
+func TestOAuthConsentRequiresConsentToken(t *testing.T) {
+	cfg, _, _ := newMockConfig(t)
+	accessToken, err := cfg.keyring.MakeJWT(uuid.New(), auth.Access{}, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed with error: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(`{"client_id": "app", "approve": true}`))
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	rec := httptest.NewRecorder()
+	cfg.handlerOAuthConsent(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("got %d for an access token, want 401", rec.Code)
+	}
+}
+
+func TestOAuthConsentRecordsOmittedRedirectURI(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	userId := uuid.New()
+	consentToken, err := cfg.keyring.MakeOAuthConsentToken(userId, time.Minute)
+	if err != nil {
+		t.Fatalf("MakeOAuthConsentToken failed with error: %v", err)
+	}
+	mock.ExpectQuery("GetOauthClient").WithArgs("app").WillReturnRows(sqlmock.NewRows(oauthClientColumns).
+		AddRow("app", nil, "Real App", "{https://app.example/callback}", uuid.New(), time.Now()))
+	// An empty redirect_uri lets the token request leave it out too.
+	mock.ExpectExec("CreateOauthAuthorizationCode").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "app", userId, "", sqlmock.AnyArg(), "challenge", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	body := `{"response_type": "code", "client_id": "app", "redirect_uri": "", "scope": "chirps:read",
+		"code_challenge": "challenge", "code_challenge_method": "S256", "approve": true}`
+	req := httptest.NewRequest(http.MethodPost, "/oauth/authorize", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+consentToken)
+	rec := httptest.NewRecorder()
+	cfg.handlerOAuthConsent(rec, req)
+
+	var got struct {
+		RedirectTo string `json:"redirect_to"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &got)
+	if rec.Code != http.StatusOK || !strings.HasPrefix(got.RedirectTo, "https://app.example/callback?code=") {
+		t.Errorf("got %d: %s, want a redirect to the registered URI", rec.Code, rec.Body)
+	}
+}
+
+func TestOAuthTokenOnlyConsumesOwnCode(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	mock.ExpectQuery("GetOauthClient").WithArgs("other").WillReturnRows(sqlmock.NewRows(oauthClientColumns).
+		AddRow("other", nil, "Other App", "{https://other.example/callback}", uuid.New(), time.Now()))
+	codeHash := auth.HashToken(cfg.tokenHashKey, "the-code")
+	mock.ExpectQuery("ConsumeOauthAuthorizationCode").WithArgs(codeHash, "other", "").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("GetOauthAuthorizationCode").WithArgs(codeHash).WillReturnRows(sqlmock.NewRows([]string{
+		"code_hash", "grant_id", "client_id", "user_id", "redirect_uri", "scopes", "code_challenge", "created_at", "expires_at", "used_at",
+	}).AddRow(codeHash, uuid.New(), "app", uuid.New(), "", "{chirps:read}", "challenge", time.Now(), time.Now().Add(time.Minute), nil))
+
+	form := url.Values{"grant_type": {"authorization_code"}, "code": {"the-code"}, "client_id": {"other"}, "code_verifier": {"verifier"}}
+	req := httptest.NewRequest(http.MethodPost, "/oauth/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	cfg.handlerOAuthToken(rec, req)
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "invalid_grant") {
+		t.Errorf("got %d: %s, want invalid_grant", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/jpheneger/chirpy/internal/oidc"
+)
+
+const (
+	oidcCookieName   = "chirpy_oidc"
+	oidcCookieMaxAge = 10 * 60
+)
+
+// handlerOIDCLogin starts federated sign in. The state, nonce and PKCE
+// verifier are kept in a short lived cookie so the callback can check them.
+func (cfg *apiConfig) handlerOIDCLogin(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidcProvider == nil {
+		respondWithError(w, http.StatusNotFound, "sign in with an identity provider is not configured", nil)
+		return
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		value, err := auth.MakeRefreshToken()
+		if err != nil {
+			respondWithError(w, http.StatusInternalServerError, "unable to start sign in", err)
+			return
+		}
+		values[i] = value
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := cfg.oidcProvider.AuthCodeURL(r.Context(), state, nonce, auth.PKCEChallenge(verifier))
+	if err != nil {
+		respondWithError(w, http.StatusBadGateway, "identity provider is unavailable", err)
+		return
+	}
+
+	http.SetCookie(w, cfg.oidcCookie(strings.Join(values, "."), oidcCookieMaxAge))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+// handlerOIDCCallback completes federated sign in and responds like
+// handlerLogin.
+func (cfg *apiConfig) handlerOIDCCallback(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidcProvider == nil {
+		respondWithError(w, http.StatusNotFound, "sign in with an identity provider is not configured", nil)
+		return
+	}
+
+	cookie, err := r.Cookie(oidcCookieName)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "sign in was not started from Chirpy", err)
+		return
+	}
+	http.SetCookie(w, cfg.oidcCookie("", -1))
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		respondWithError(w, http.StatusUnauthorized, "identity provider refused sign in: "+query.Get("error"), nil)
+		return
+	}
+	values := strings.Split(cookie.Value, ".")
+	if len(values) != 3 || subtle.ConstantTimeCompare([]byte(values[0]), []byte(query.Get("state"))) != 1 {
+		respondWithError(w, http.StatusBadRequest, "sign in state does not match", nil)
+		return
+	}
+	nonce, verifier := values[1], values[2]
+
+	claims, err := cfg.oidcProvider.Exchange(r.Context(), query.Get("code"), verifier, nonce)
+	if err != nil {
+		respondWithError(w, http.StatusUnauthorized, "unable to verify identity provider sign in", err)
+		return
+	}
+
+	user, err := cfg.federatedUser(claims)
+	if errors.Is(err, errUnverifiedFederatedEmail) {
+		respondWithError(w, http.StatusForbidden, err.Error(), nil)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to sign in", err)
+		return
+	}
+
+	mfaEnabled, err := cfg.mfaEnabled(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch two-factor settings from db", err)
+		return
+	}
+	if mfaEnabled {
+		cfg.respondWithMFAChallenge(w, user.ID)
+		return
+	}
+
+	cfg.respondWithSession(w, r, user)
+}
+
+var errUnverifiedFederatedEmail = errors.New("identity provider has not verified this email address")
+
+// federatedUser finds the user linked to a provider identity. An identity
+// seen for the first time is linked to the user with the same email address,
+// or to a new user without a password, but only if the provider has verified
+// the address.
+func (cfg *apiConfig) federatedUser(claims oidc.Claims) (database.User, error) {
+	ctx := context.Background()
+	identity, err := cfg.db.GetUserIdentity(ctx, database.GetUserIdentityParams{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+	})
+	if err == nil {
+		err = cfg.db.TouchUserIdentity(ctx, database.TouchUserIdentityParams{
+			Issuer:  claims.Issuer,
+			Subject: claims.Subject,
+			Email:   claims.Email,
+		})
+		if err != nil {
+			return database.User{}, err
+		}
+		return cfg.db.GetUserById(ctx, identity.UserID)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return database.User{}, err
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return database.User{}, errUnverifiedFederatedEmail
+	}
+
+	user, err := cfg.db.GetUserByEmail(ctx, claims.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = cfg.db.CreatePasswordlessUser(ctx, claims.Email)
+		if err != nil {
+			return database.User{}, err
+		}
+	} else if err != nil {
+		return database.User{}, err
+	} else if user, err = cfg.claimUnverifiedAccount(user); err != nil {
+		return database.User{}, err
+	}
+
+	err = cfg.db.CreateUserIdentity(ctx, database.CreateUserIdentityParams{
+		Issuer:  claims.Issuer,
+		Subject: claims.Subject,
+		UserID:  user.ID,
+		Email:   claims.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+	return cfg.db.GetUserById(ctx, user.ID)
+}
+
+func (cfg *apiConfig) oidcCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     oidcCookieName,
+		Value:    value,
+		Path:     "/api/auth/oidc/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(cfg.publicURL, "https://"),
+		SameSite: http.SameSiteLaxMode,
+	}
+}
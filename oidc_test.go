@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/oidc"
+)
+
+// newStandInIdP serves the discovery document, key set and token endpoint of
+// a minimal OpenID Connect provider. Its token endpoint returns idToken for
+// code "good-code" if the PKCE verifier matches the last challenge it saw.
+func newStandInIdP(t *testing.T, private ed25519.PrivateKey, idToken func(issuer string) string) (*httptest.Server, *string) {
+	t.Helper()
+	kr, err := auth.NewKeyring(auth.SigningKey{ID: "idp-key", Private: private})
+	if err != nil {
+		t.Fatalf("NewKeyring: %v", err)
+	}
+	challenge := new(string)
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 server.URL,
+			"authorization_endpoint": server.URL + "/authorize",
+			"token_endpoint":         server.URL + "/token",
+			"jwks_uri":               server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(kr.JWKS())
+	})
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, _ := r.BasicAuth()
+		if clientID != "chirpy" || secret != "idp-secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		r.ParseForm()
+		if r.PostForm.Get("code") != "good-code" || !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), *challenge) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"id_token": idToken(server.URL)})
+	})
+	return server, challenge
+}
+
+func TestOIDCProvider(t *testing.T) {
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	nonce := "the-nonce"
+	signIDToken := func(issuer string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, oidc.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer,
+				Subject:   "idp-user-1",
+				Audience:  jwt.ClaimStrings{"chirpy"},
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Nonce:         nonce,
+			Email:         "user@example.com",
+			EmailVerified: true,
+		})
+		token.Header["kid"] = "idp-key"
+		signed, err := token.SignedString(private)
+		if err != nil {
+			t.Fatalf("signing id token: %v", err)
+		}
+		return signed
+	}
+	idp, challenge := newStandInIdP(t, private, signIDToken)
+
+	provider := &oidc.Provider{
+		Issuer:       idp.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "idp-secret",
+		RedirectURL:  "http://localhost:8080/api/auth/oidc/callback",
+	}
+	verifier, _ := auth.MakeRefreshToken()
+	authURL, err := provider.AuthCodeURL(context.Background(), "the-state", nonce, auth.PKCEChallenge(verifier))
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if u.Query().Get("state") != "the-state" || u.Query().Get("nonce") != nonce || u.Query().Get("code_challenge_method") != "S256" {
+		t.Errorf("AuthCodeURL = %s", authURL)
+	}
+	*challenge = u.Query().Get("code_challenge")
+
+	claims, err := provider.Exchange(context.Background(), "good-code", verifier, nonce)
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Subject != "idp-user-1" || claims.Email != "user@example.com" || !claims.EmailVerified {
+		t.Errorf("Exchange returned claims %+v", claims)
+	}
+
+	if _, err := provider.Exchange(context.Background(), "good-code", verifier, "another-nonce"); err == nil {
+		t.Errorf("Exchange accepted an id token with the wrong nonce")
+	}
+	if _, err := provider.Exchange(context.Background(), "good-code", verifier+"0", nonce); err == nil {
+		t.Errorf("Exchange succeeded with the wrong PKCE verifier")
+	}
+
+	other := &oidc.Provider{Issuer: idp.URL, ClientID: "another-app", ClientSecret: "idp-secret"}
+	if _, err := other.VerifyIDToken(context.Background(), signIDToken(idp.URL), nonce); err == nil {
+		t.Errorf("VerifyIDToken accepted an id token for another audience")
+	}
+}
+
+// oidcCallback runs handlerOIDCCallback against a stand-in provider that
+// vouches for email.
+func oidcCallback(t *testing.T, cfg *apiConfig, email string) *httptest.ResponseRecorder {
+	t.Helper()
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	nonce := "the-nonce"
+	verifier, _ := auth.MakeRefreshToken()
+	idp, challenge := newStandInIdP(t, private, func(issuer string) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, oidc.Claims{
+			RegisteredClaims: jwt.RegisteredClaims{
+				Issuer:    issuer,
+				Subject:   "idp-user-1",
+				Audience:  jwt.ClaimStrings{"chirpy"},
+				IssuedAt:  jwt.NewNumericDate(time.Now()),
+				ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+			},
+			Nonce:         nonce,
+			Email:         email,
+			EmailVerified: true,
+		})
+		token.Header["kid"] = "idp-key"
+		signed, err := token.SignedString(private)
+		if err != nil {
+			t.Fatalf("signing id token: %v", err)
+		}
+		return signed
+	})
+	*challenge = auth.PKCEChallenge(verifier)
+	cfg.oidcProvider = &oidc.Provider{
+		Issuer:       idp.URL,
+		ClientID:     "chirpy",
+		ClientSecret: "idp-secret",
+		RedirectURL:  cfg.publicURL + "/api/auth/oidc/callback",
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?state=the-state&code=good-code", nil)
+	req.AddCookie(&http.Cookie{Name: oidcCookieName, Value: "the-state." + nonce + "." + verifier})
+	rec := httptest.NewRecorder()
+	cfg.handlerOIDCCallback(rec, req)
+	return rec
+}
+
+func TestOIDCCallbackClaimsUnverifiedAccount(t *testing.T) {
+	cfg, mock, executed := newMockConfig(t)
+	squatted := unverifiedUser("owner@example.com")
+	claimed := squatted
+	claimed.HashedPassword = sql.NullString{}
+	claimed.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	mock.ExpectQuery("GetUserIdentity").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("GetUserByEmail").WithArgs(squatted.Email).WillReturnRows(userRow(squatted))
+	mock.ExpectQuery("ClaimUnverifiedUser").WithArgs(squatted.ID, squatted.Email).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(squatted.ID))
+	mock.ExpectQuery("GetUserById").WithArgs(squatted.ID).WillReturnRows(userRow(claimed))
+	mock.ExpectExec("CreateUserIdentity").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("GetUserById").WithArgs(squatted.ID).WillReturnRows(userRow(claimed))
+	mock.ExpectQuery("GetTotpCredential").WillReturnError(sql.ErrNoRows)
+	expectSession(mock, squatted.ID)
+
+	rec := oidcCallback(t, cfg, squatted.Email)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	var body respBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Id != squatted.ID || !body.EmailVerified {
+		t.Errorf("got body %s, want a verified session for %s", rec.Body, squatted.ID)
+	}
+
+	// Everything the squatter could get back in with is revoked along with
+	// the verification, in one statement. The expectations above run in
+	// order, so the caller's identity is only linked after the claim.
+	var claim string
+	for _, query := range *executed {
+		if strings.HasPrefix(query, "-- name: ClaimUnverifiedUser ") {
+			claim = query
+		}
+	}
+	for _, table := range []string{"refresh_tokens", "personal_access_tokens", "oauth_tokens", "oauth_authorization_codes", "oauth_clients", "totp_credentials", "mfa_recovery_codes", "password_reset_tokens", "user_identities"} {
+		if !strings.Contains(claim, table) {
+			t.Errorf("claiming an account leaves %s in place", table)
+		}
+	}
+}
+
+func TestOIDCCallbackLinksVerifiedAccount(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	user := unverifiedUser("owner@example.com")
+	user.EmailVerifiedAt = sql.NullTime{Time: time.Now(), Valid: true}
+
+	// A verified account keeps its password and credentials: there is no
+	// ClaimUnverifiedUser between the lookup and the link.
+	mock.ExpectQuery("GetUserIdentity").WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("GetUserByEmail").WithArgs(user.Email).WillReturnRows(userRow(user))
+	mock.ExpectExec("CreateUserIdentity").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("GetUserById").WithArgs(user.ID).WillReturnRows(userRow(user))
+	mock.ExpectQuery("GetTotpCredential").WillReturnError(sql.ErrNoRows)
+	expectSession(mock, user.ID)
+
+	if rec := oidcCallback(t, cfg, user.Email); rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+}
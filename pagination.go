@@ -0,0 +1,98 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 100
+)
+
+// pageCursor marks a position in a list ordered by (created_at, id), such as
+// chirps or likes, or by (rank, created_at, id) for search results. Clients
+// get it as an opaque string in a Link header.
+type pageCursor struct {
+	Rank      float32   `json:"r,omitempty"`
+	CreatedAt time.Time `json:"t"`
+	ID        uuid.UUID `json:"id"`
+	// Before pages back towards the start of the list, for a previous page.
+	Before bool `json:"b,omitempty"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+func (c pageCursor) String() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func parsePageCursor(value string) (pageCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	cursor := pageCursor{}
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID == uuid.Nil {
+		return pageCursor{}, errInvalidCursor
+	}
+	return cursor, nil
+}
+
+// parsePageSize reads the limit query parameter, defaulting to
+// defaultPageSize.
+func parsePageSize(query url.Values) (int, error) {
+	value := query.Get("limit")
+	if value == "" {
+		return defaultPageSize, nil
+	}
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 || limit > maxPageSize {
+		return 0, errors.New("limit must be between 1 and " + strconv.Itoa(maxPageSize))
+	}
+	return limit, nil
+}
+
+// parseTimeParam reads an optional RFC 3339 query parameter. Chirp times are
+// stored without a time zone, in UTC.
+func parseTimeParam(query url.Values, name string) (time.Time, bool, error) {
+	value := query.Get(name)
+	if value == "" {
+		return time.Time{}, false, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, false, errors.New(name + " must be an RFC 3339 timestamp")
+	}
+	return t.UTC(), true, nil
+}
+
+// setPageLinks sets an RFC 8288 Link header pointing at the next and previous
+// pages, keeping every other query parameter of the request. An empty cursor
+// means there is no such page.
+func (cfg *apiConfig) setPageLinks(w http.ResponseWriter, r *http.Request, next, prev string) {
+	links := []string{}
+	for _, page := range []struct {
+		rel    string
+		cursor string
+	}{{"next", next}, {"prev", prev}} {
+		if page.cursor == "" {
+			continue
+		}
+		query := r.URL.Query()
+		query.Set("cursor", page.cursor)
+		links = append(links, "<"+cfg.publicURL+r.URL.Path+"?"+query.Encode()+`>; rel="`+page.rel+`"`)
+	}
+	if len(links) > 0 {
+		w.Header().Set("Link", strings.Join(links, ", "))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPageCursor(t *testing.T) {
+	cursor := pageCursor{
+		CreatedAt: time.Date(2026, 3, 1, 12, 0, 0, 123456000, time.UTC),
+		ID:        uuid.New(),
+		Before:    true,
+	}
+	got, err := parsePageCursor(cursor.String())
+	if err != nil {
+		t.Fatalf("parsePageCursor failed with error: %v", err)
+	}
+	if !got.CreatedAt.Equal(cursor.CreatedAt) || got.ID != cursor.ID || !got.Before {
+		t.Errorf("got %+v, want %+v", got, cursor)
+	}
+
+	for _, value := range []string{"not a cursor", "e30", cursor.String() + "!"} {
+		if _, err := parsePageCursor(value); err == nil {
+			t.Errorf("parsePageCursor(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestParsePageSize(t *testing.T) {
+	tests := []struct {
+		limit   string
+		want    int
+		wantErr bool
+	}{
+		{"", defaultPageSize, false},
+		{"1", 1, false},
+		{"100", 100, false},
+		{"0", 0, true},
+		{"101", 0, true},
+		{"ten", 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePageSize(url.Values{"limit": {tt.limit}})
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parsePageSize(%q) = %d, %v, want %d, error %v", tt.limit, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
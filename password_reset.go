@@ -0,0 +1,142 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+	"github.com/jpheneger/chirpy/internal/mailer"
+)
+
+const passwordResetTTL = 1 * time.Hour
+
+func (cfg *apiConfig) handlerPasswordResetRequest(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Email string `json:"email"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	if !cfg.throttleMail(w, r, reqBody.Email) {
+		return
+	}
+
+	// Respond the same way whether or not the account exists, so this
+	// endpoint can't be used to discover registered emails.
+	user, err := cfg.db.GetUserByEmail(context.Background(), reqBody.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		w.WriteHeader(http.StatusAccepted)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
+		return
+	}
+
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to create reset token", err)
+		return
+	}
+	err = cfg.db.CreatePasswordResetToken(context.Background(), database.CreatePasswordResetTokenParams{
+		TokenHash: auth.HashToken(cfg.tokenHashKey, token),
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to store reset token", err)
+		return
+	}
+
+	link := cfg.publicURL + "/app/static/reset-password.html?token=" + url.QueryEscape(token)
+	cfg.sendMail(mailer.Message{
+		To:      user.Email,
+		Subject: "Reset your Chirpy password",
+		Body: fmt.Sprintf("Someone asked to reset the password for your Chirpy account.\n\n"+
+			"Follow this link within the next hour to choose a new one:\n\n%s\n\n"+
+			"If this wasn't you, you can ignore this email.\n", link),
+	})
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (cfg *apiConfig) handlerPasswordResetConfirm(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	if reqBody.Password == "" {
+		respondWithError(w, http.StatusBadRequest, "password is required", nil)
+		return
+	}
+
+	resetToken, err := cfg.db.ConsumePasswordResetToken(context.Background(), auth.HashToken(cfg.tokenHashKey, reqBody.Token))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusBadRequest, "invalid or expired reset token", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to use reset token", err)
+		return
+	}
+
+	hpw, err := cfg.passwordHasher.Hash(reqBody.Password)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Unable to hash password", err)
+		return
+	}
+	err = cfg.db.UpdateUserPassword(context.Background(), database.UpdateUserPasswordParams{
+		ID:             resetToken.UserID,
+		HashedPassword: sql.NullString{String: hpw, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to update password", err)
+		return
+	}
+
+	// Anyone who knew the old password may still hold a session or a
+	// reset link; cut them all off.
+	err = cfg.db.InvalidatePasswordResetTokens(context.Background(), resetToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to invalidate reset tokens", err)
+		return
+	}
+	err = cfg.db.RevokeAllSessionsForUser(context.Background(), resetToken.UserID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to revoke sessions", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// sendMail delivers msg in the background so that response times don't
+// reveal whether an email was sent.
+func (cfg *apiConfig) sendMail(msg mailer.Message) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := cfg.mailer.Send(ctx, msg); err != nil {
+			log.Printf("unable to send %q to %s: %v", msg.Subject, msg.To, err)
+		}
+	}()
+}
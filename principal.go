@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+)
+
+type credentialType string
+
+const (
+	credentialSession             credentialType = "session"
+	credentialPersonalAccessToken credentialType = "personal_access_token"
+	credentialOAuthToken          credentialType = "oauth"
+)
+
+// principal is the authenticated caller of a request, stored in its context
+// by the auth middlewares.
+type principal struct {
+	UserID     uuid.UUID
+	Credential credentialType
+	// Scopes limit personal access and OAuth tokens. A session may do
+	// anything its user can.
+	Scopes []string
+	// Access holds a session's roles and permissions. Other credentials
+	// carry none.
+	Access auth.Access
+}
+
+func (p principal) hasScope(scope string) bool {
+	return p.Credential == credentialSession || auth.HasScope(p.Scopes, scope)
+}
+
+type principalKey struct{}
+
+// principalFrom returns the principal stored by the auth middlewares, which
+// has a nil UserID for an anonymous request.
+func principalFrom(ctx context.Context) principal {
+	p, _ := ctx.Value(principalKey{}).(principal)
+	return p
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+// authenticate identifies the caller from a bearer token. Personal access
+// and OAuth tokens are told apart by their prefix and access tokens by their
+// token_type claim, so no other kind of token is accepted as any of them.
+func (cfg *apiConfig) authenticate(r *http.Request) (principal, error) {
+	token, err := auth.GetBearerToken(r.Header)
+	if errors.Is(err, auth.ErrNoAuthorization) {
+		return principal{}, err
+	} else if err != nil {
+		return principal{}, fmt.Errorf("%w: %v", errInvalidToken, err)
+	}
+
+	switch {
+	case auth.IsPersonalAccessToken(token):
+		pat, err := cfg.db.UsePersonalAccessToken(context.Background(), auth.HashToken(cfg.tokenHashKey, token))
+		if errors.Is(err, sql.ErrNoRows) {
+			return principal{}, fmt.Errorf("%w: personal access token is revoked or expired", errInvalidToken)
+		} else if err != nil {
+			return principal{}, err
+		}
+		return principal{UserID: pat.UserID, Credential: credentialPersonalAccessToken, Scopes: pat.Scopes}, nil
+
+	case auth.IsOAuthAccessToken(token):
+		oauthToken, err := cfg.db.GetActiveOauthToken(context.Background(), auth.HashToken(cfg.tokenHashKey, token))
+		if errors.Is(err, sql.ErrNoRows) {
+			return principal{}, fmt.Errorf("%w: OAuth token is revoked or expired", errInvalidToken)
+		} else if err != nil {
+			return principal{}, err
+		}
+		if oauthToken.TokenType != "access_token" {
+			return principal{}, fmt.Errorf("%w: not an OAuth access token", errInvalidToken)
+		}
+		return principal{UserID: oauthToken.UserID, Credential: credentialOAuthToken, Scopes: oauthToken.Scopes}, nil
+
+	default:
+		userId, access, err := cfg.keyring.ValidateAccessToken(token)
+		if err != nil {
+			return principal{}, fmt.Errorf("%w: %v", errInvalidToken, err)
+		}
+		return principal{UserID: userId, Credential: credentialSession, Access: access}, nil
+	}
+}
+
+// middlewareAuth lets through requests from any credential granting scope.
+func (cfg *apiConfig) middlewareAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewarePrincipal(func(w http.ResponseWriter, r *http.Request, p principal) bool {
+		if !p.hasScope(scope) {
+			respondInsufficientScope(w, scope, "token does not grant the "+scope+" scope")
+			return false
+		}
+		return true
+	}, next)
+}
+
+// middlewareOptionalAuth is for public endpoints that tell the caller apart
+// when it can. A request without a valid token granting scope is served
+// anonymously rather than rejected, so it has no principal.
+func (cfg *apiConfig) middlewareOptionalAuth(scope string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := cfg.authenticate(r)
+		if err != nil && !errors.Is(err, auth.ErrNoAuthorization) && !errors.Is(err, errInvalidToken) {
+			respondWithError(w, http.StatusInternalServerError, "unable to authenticate", err)
+			return
+		}
+		if err != nil || !p.hasScope(scope) {
+			next(w, r)
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+// middlewareSession only lets through requests authenticated with an access
+// token from a login. It guards endpoints that manage credentials, which
+// personal access and OAuth tokens must not be able to reach.
+func (cfg *apiConfig) middlewareSession(next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewarePrincipal(requireSession, next)
+}
+
+// middlewarePermission only lets through sessions whose user has
+// permission.
+func (cfg *apiConfig) middlewarePermission(permission string, next http.HandlerFunc) http.HandlerFunc {
+	return cfg.middlewarePrincipal(func(w http.ResponseWriter, r *http.Request, p principal) bool {
+		if !requireSession(w, r, p) {
+			return false
+		}
+		if !p.Access.HasPermission(permission) {
+			respondWithError(w, http.StatusForbidden, "missing permission "+permission, nil)
+			return false
+		}
+		return true
+	}, next)
+}
+
+func requireSession(w http.ResponseWriter, r *http.Request, p principal) bool {
+	if p.Credential != credentialSession {
+		respondInsufficientScope(w, "", "this endpoint requires a login session")
+		return false
+	}
+	return true
+}
+
+func (cfg *apiConfig) middlewarePrincipal(allow func(http.ResponseWriter, *http.Request, principal) bool, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p, err := cfg.authenticate(r)
+		if err != nil {
+			if errors.Is(err, auth.ErrNoAuthorization) {
+				respondUnauthorized(w, "", "authorization required", err)
+			} else if errors.Is(err, errInvalidToken) {
+				respondUnauthorized(w, "invalid_token", "invalid authorization token provided", err)
+			} else {
+				respondWithError(w, http.StatusInternalServerError, "unable to authenticate", err)
+			}
+			return
+		}
+		if !allow(w, r, p) {
+			return
+		}
+		next(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, p)))
+	}
+}
+
+// respondUnauthorized sends a 401 with the RFC 6750 challenge.
+func respondUnauthorized(w http.ResponseWriter, errorCode, msg string, err error) {
+	challenge := `Bearer realm="chirpy"`
+	if errorCode != "" {
+		challenge += fmt.Sprintf(`, error=%q`, errorCode)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithError(w, http.StatusUnauthorized, msg, err)
+}
+
+func respondInsufficientScope(w http.ResponseWriter, scope, msg string) {
+	challenge := `Bearer realm="chirpy", error="insufficient_scope"`
+	if scope != "" {
+		challenge += fmt.Sprintf(`, scope=%q`, scope)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	respondWithError(w, http.StatusForbidden, msg, nil)
+}
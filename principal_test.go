@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+)
+
+func TestMiddlewareOptionalAuth(t *testing.T) {
+	userId := uuid.New()
+	cfg, mock, _ := newMockConfig(t)
+	token, err := cfg.keyring.MakeJWT(userId, auth.Access{}, time.Hour)
+	if err != nil {
+		t.Fatalf("MakeJWT failed with error: %v", err)
+	}
+	expired, err := cfg.keyring.MakeJWT(userId, auth.Access{}, -time.Minute)
+	if err != nil {
+		t.Fatalf("MakeJWT failed with error: %v", err)
+	}
+	pat, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("MakePersonalAccessToken failed with error: %v", err)
+	}
+	patColumns := []string{"id", "user_id", "name", "token_hash", "scopes", "created_at", "expires_at", "last_used_at", "revoked_at"}
+
+	tests := []struct {
+		name          string
+		authorization string
+		scopes        string
+		wantUser      uuid.UUID
+	}{
+		{"anonymous", "", "", uuid.Nil},
+		{"session", "Bearer " + token, "", userId},
+		{"invalid token", "Bearer nope", "", uuid.Nil},
+		{"expired token", "Bearer " + expired, "", uuid.Nil},
+		{"token with scope", "Bearer " + pat, "{chirps:read}", userId},
+		{"token without scope", "Bearer " + pat, "{chirps:write}", uuid.Nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.scopes != "" {
+				mock.ExpectQuery("UsePersonalAccessToken").WillReturnRows(sqlmock.NewRows(patColumns).
+					AddRow(uuid.New(), userId, "reader", "hash", tt.scopes, time.Now(), nil, time.Now(), nil))
+			}
+			gotUser := uuid.New()
+			handler := cfg.middlewareOptionalAuth(auth.ScopeChirpsRead, func(w http.ResponseWriter, r *http.Request) {
+				gotUser = principalFrom(r.Context()).UserID
+			})
+			req := httptest.NewRequest(http.MethodGet, "/api/chirps", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			handler(rec, req)
+
+			if rec.Code != http.StatusOK || gotUser != tt.wantUser {
+				t.Errorf("got %d for %s, want 200 for %s", rec.Code, gotUser, tt.wantUser)
+			}
+		})
+	}
+}
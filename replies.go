@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+type ChirpThread struct {
+	// Ancestors are the chirps replied to, from the top of the thread down.
+	Ancestors []Chirp `json:"ancestors"`
+	Chirp     Chirp   `json:"chirp"`
+	// Replies is one page of the reply tree, depth first.
+	Replies []ThreadReply `json:"replies"`
+}
+
+type ThreadReply struct {
+	Chirp
+	// Depth is 1 for direct replies to the chirp, 2 for their replies and
+	// so on.
+	Depth int32 `json:"depth"`
+}
+
+func (cfg *apiConfig) handlerCreateReply(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	// Replies to a rechirp go to the chirp it reposts.
+	parent, err := cfg.originalChirp(chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+
+	cfg.createChirp(w, r, uuid.NullUUID{UUID: parent.ID, Valid: true})
+}
+
+func (cfg *apiConfig) handlerChirpThread(w http.ResponseWriter, r *http.Request) {
+	chirpId, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid chirp id", err)
+		return
+	}
+	query := r.URL.Query()
+	limit, err := parsePageSize(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	var afterPath []byte
+	if value := query.Get("cursor"); value != "" {
+		afterPath, err = parseThreadCursor(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+	}
+
+	// Threads stay readable when a chirp in them is deleted.
+	chirp, err := cfg.db.GetChirpById(context.Background(), chirpId)
+	if errors.Is(err, sql.ErrNoRows) {
+		chirp, err = cfg.db.GetDeletedChirp(context.Background(), chirpId)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondWithError(w, http.StatusNotFound, "Unable to get chrip by ID: "+chirpId.String(), err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch chirp from db", err)
+		return
+	}
+
+	ancestors, err := cfg.db.GetChirpAncestors(context.Background(), chirp.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get thread from db", err)
+		return
+	}
+	replies, err := cfg.db.GetReplyTree(context.Background(), database.GetReplyTreeParams{
+		ReplyToID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		AfterPath:  afterPath,
+		MaxResults: int32(limit + 1),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get replies from db", err)
+		return
+	}
+	if len(replies) > limit {
+		replies = replies[:limit]
+		cfg.setPageLinks(w, r, base64.RawURLEncoding.EncodeToString(replies[limit-1].Path), "")
+	}
+
+	// Every chirp in the thread is decorated together: ancestors, then the
+	// chirp, then its replies.
+	chirps := []Chirp{}
+	for _, ancestor := range ancestors {
+		chirps = append(chirps, chirpOrPlaceholder(ancestor))
+	}
+	chirps = append(chirps, chirpOrPlaceholder(chirp))
+	for _, reply := range replies {
+		chirps = append(chirps, chirpOrPlaceholder(database.Chirp{
+			ID:            reply.ID,
+			CreatedAt:     reply.CreatedAt,
+			UpdatedAt:     reply.UpdatedAt,
+			Body:          reply.Body,
+			UserID:        reply.UserID,
+			RevisionCount: reply.RevisionCount,
+			DeletedAt:     reply.DeletedAt,
+			ReplyToID:     reply.ReplyToID,
+			ReplyCount:    reply.ReplyCount,
+			RechirpOfID:   reply.RechirpOfID,
+			QuoteOfID:     reply.QuoteOfID,
+			LikeCount:     reply.LikeCount,
+		}))
+	}
+	if err := cfg.decorateChirps(r, chirps); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details from db", err)
+		return
+	}
+
+	responseBody := ChirpThread{
+		Ancestors: append([]Chirp{}, chirps[:len(ancestors)]...),
+		Chirp:     chirps[len(ancestors)],
+		Replies:   []ThreadReply{},
+	}
+	for i, reply := range replies {
+		responseBody.Replies = append(responseBody.Replies, ThreadReply{
+			Chirp: chirps[len(ancestors)+1+i],
+			Depth: reply.Depth,
+		})
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
+
+// threadPathSegment is the size of each level of a reply's path: its
+// created_at in microseconds and its id.
+const threadPathSegment = 8 + 16
+
+func parseThreadCursor(value string) ([]byte, error) {
+	path, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(path) == 0 || len(path)%threadPathSegment != 0 {
+		return nil, errInvalidCursor
+	}
+	return path, nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+func TestParseThreadCursor(t *testing.T) {
+	path := make([]byte, 2*threadPathSegment)
+	rand.Read(path)
+	got, err := parseThreadCursor(base64.RawURLEncoding.EncodeToString(path))
+	if err != nil || string(got) != string(path) {
+		t.Errorf("parseThreadCursor = %x, %v, want %x", got, err, path)
+	}
+	for _, value := range []string{"", "!!", base64.RawURLEncoding.EncodeToString(path[:threadPathSegment+1])} {
+		if _, err := parseThreadCursor(value); err == nil {
+			t.Errorf("parseThreadCursor(%q) succeeded, want error", value)
+		}
+	}
+}
+
+func TestChirpThreadDecoratesEveryChirp(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	userId := uuid.New()
+	root := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "root", UserID: uuid.New()}
+	chirp := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "reply", UserID: uuid.New(),
+		ReplyToID: uuid.NullUUID{UUID: root.ID, Valid: true}}
+	quoted := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "quoted", UserID: uuid.New()}
+	reply := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "quote reply", UserID: uuid.New(),
+		ReplyToID: uuid.NullUUID{UUID: chirp.ID, Valid: true}, QuoteOfID: uuid.NullUUID{UUID: quoted.ID, Valid: true}}
+
+	mock.ExpectQuery("GetChirpById").WithArgs(chirp.ID).WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(chirp)...))
+	mock.ExpectQuery("GetChirpAncestors").WithArgs(chirp.ID).WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(root)...))
+	mock.ExpectQuery("GetReplyTree").WillReturnRows(sqlmock.NewRows(append(chirpColumns, "depth", "path")).
+		AddRow(append(chirpValues(reply), 1, []byte{1})...))
+	mock.ExpectQuery("GetChirpsByIds").WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(quoted)...))
+	mock.ExpectQuery("GetLikedChirpIds").WithArgs(userId, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"chirp_id"}).AddRow(root.ID).AddRow(reply.ID))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/chirps/"+chirp.ID.String()+"/thread", nil)
+	req.SetPathValue("chirpID", chirp.ID.String())
+	rec := httptest.NewRecorder()
+	cfg.handlerChirpThread(rec, asUser(req, userId))
+
+	var got ChirpThread
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	liked := func(c Chirp) bool { return c.LikedByMe != nil && *c.LikedByMe }
+	if len(got.Ancestors) != 1 || !liked(got.Ancestors[0]) {
+		t.Errorf("ancestors = %+v, want the root liked", got.Ancestors)
+	}
+	if got.Chirp.Id != chirp.ID || got.Chirp.LikedByMe == nil || liked(got.Chirp) {
+		t.Errorf("chirp = %+v, want it decorated as not liked", got.Chirp)
+	}
+	if len(got.Replies) != 1 || !liked(got.Replies[0].Chirp) || got.Replies[0].Original == nil || got.Replies[0].Original.Id != quoted.ID {
+		t.Errorf("replies = %+v, want the liked quote with its original", got.Replies)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+// makeAccessToken issues an access token carrying the user's current roles
+// and permissions.
+func (cfg *apiConfig) makeAccessToken(userID uuid.UUID, expiresIn time.Duration) (string, error) {
+	access, err := cfg.userAccess(userID)
+	if err != nil {
+		return "", err
+	}
+	return cfg.keyring.MakeJWT(userID, access, expiresIn)
+}
+
+func (cfg *apiConfig) userAccess(userID uuid.UUID) (auth.Access, error) {
+	roles, err := cfg.db.GetUserRoles(context.Background(), userID)
+	if err != nil {
+		return auth.Access{}, err
+	}
+	permissions, err := cfg.db.GetUserPermissions(context.Background(), userID)
+	if err != nil {
+		return auth.Access{}, err
+	}
+	return auth.Access{
+		Roles:       append([]string{auth.RoleUser}, roles...),
+		Permissions: permissions,
+	}, nil
+}
+
+func (cfg *apiConfig) handlerListUserRoles(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+
+	access, err := cfg.userAccess(userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get roles from db", err)
+		return
+	}
+	respondWithJSON(w, http.StatusOK, access)
+}
+
+func (cfg *apiConfig) handlerGrantRole(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Role string `json:"role"`
+	}
+
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err = decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	exists, err := cfg.db.RoleExists(context.Background(), reqBody.Role)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get roles from db", err)
+		return
+	}
+	if !exists || reqBody.Role == auth.RoleUser {
+		respondWithError(w, http.StatusBadRequest, "unknown role: "+reqBody.Role, nil)
+		return
+	}
+	if _, err := cfg.db.GetUserById(context.Background(), userId); err != nil {
+		respondWithError(w, http.StatusNotFound, "Unable to get user by ID: "+userId.String(), err)
+		return
+	}
+
+	grantedBy := principalFrom(r.Context()).UserID
+	err = cfg.db.GrantRole(context.Background(), database.GrantRoleParams{
+		UserID:    userId,
+		Role:      reqBody.Role,
+		GrantedBy: uuid.NullUUID{UUID: grantedBy, Valid: true},
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to grant role", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeRole(w http.ResponseWriter, r *http.Request) {
+	userId, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid user id", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeRole(context.Background(), database.RevokeRoleParams{
+		UserID: userId,
+		Role:   r.PathValue("role"),
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to revoke role", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "user does not have this role", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
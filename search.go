@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"unicode"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+type ChirpSearchResult struct {
+	Chirp
+	Rank float32 `json:"rank"`
+	// Snippet is the chirp body as HTML, with matches wrapped in <mark>.
+	Snippet string `json:"snippet"`
+}
+
+func (cfg *apiConfig) handlerSearchChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	tsquery, err := buildSearchQuery(query.Get("q"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params := database.SearchChirpsParams{Query: tsquery}
+
+	if authorId := query.Get("author_id"); authorId != "" {
+		userId, err := uuid.Parse(authorId)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid author id", err)
+			return
+		}
+		params.AuthorID = uuid.NullUUID{UUID: userId, Valid: true}
+	}
+
+	limit, err := parsePageSize(query)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, err.Error(), err)
+		return
+	}
+	params.MaxResults = int32(limit + 1)
+	if value := query.Get("cursor"); value != "" {
+		cursor, err := parsePageCursor(value)
+		if err != nil {
+			respondWithError(w, http.StatusBadRequest, "invalid cursor", err)
+			return
+		}
+		params.CursorRank = sql.NullFloat64{Float64: float64(cursor.Rank), Valid: true}
+		params.CursorCreatedAt = sql.NullTime{Time: cursor.CreatedAt, Valid: true}
+		params.CursorID = uuid.NullUUID{UUID: cursor.ID, Valid: true}
+	}
+
+	rows, err := cfg.db.SearchChirps(context.Background(), params)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't search chirps", err)
+		return
+	}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[limit-1]
+		cfg.setPageLinks(w, r, pageCursor{Rank: last.Rank, CreatedAt: last.CreatedAt, ID: last.ID}.String(), "")
+	}
+
+	chirps := []Chirp{}
+	for _, row := range rows {
+		chirps = append(chirps, chirpFromDB(database.Chirp{
+			ID:            row.ID,
+			CreatedAt:     row.CreatedAt,
+			UpdatedAt:     row.UpdatedAt,
+			Body:          row.Body,
+			UserID:        row.UserID,
+			RevisionCount: row.RevisionCount,
+			ReplyToID:     row.ReplyToID,
+			ReplyCount:    row.ReplyCount,
+			RechirpOfID:   row.RechirpOfID,
+			QuoteOfID:     row.QuoteOfID,
+			LikeCount:     row.LikeCount,
+		}))
+	}
+	if err := cfg.decorateChirps(r, chirps); err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get chirp details from db", err)
+		return
+	}
+
+	responseBody := []ChirpSearchResult{}
+	for i, chirp := range chirps {
+		responseBody = append(responseBody, ChirpSearchResult{Chirp: chirp, Rank: rows[i].Rank, Snippet: rows[i].Snippet})
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
+
+// buildSearchQuery turns a search box query into to_tsquery syntax. Every
+// term must match; "quoted phrases" must match in order and a trailing * on
+// a word matches any word starting with it. Everything but letters and
+// digits is dropped, so the result is always valid tsquery syntax.
+func buildSearchQuery(q string) (string, error) {
+	terms := []string{}
+	for i, part := range strings.Split(q, `"`) {
+		// Odd parts are inside quotes.
+		if i%2 == 1 {
+			if phrase := searchPhrase(strings.Fields(part)); phrase != "" {
+				terms = append(terms, phrase)
+			}
+			continue
+		}
+		for _, field := range strings.Fields(part) {
+			if phrase := searchPhrase([]string{field}); phrase != "" {
+				terms = append(terms, phrase)
+			}
+		}
+	}
+	if len(terms) == 0 {
+		return "", errors.New("search query must contain a word")
+	}
+	return strings.Join(terms, " & "), nil
+}
+
+func searchPhrase(fields []string) string {
+	lexemes := []string{}
+	for _, field := range fields {
+		words := strings.FieldsFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		if len(words) == 0 {
+			continue
+		}
+		if strings.HasSuffix(field, "*") {
+			words[len(words)-1] += ":*"
+		}
+		lexemes = append(lexemes, words...)
+	}
+	if len(lexemes) > 1 {
+		return "(" + strings.Join(lexemes, " <-> ") + ")"
+	}
+	return strings.Join(lexemes, "")
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+func TestBuildSearchQuery(t *testing.T) {
+	tests := []struct {
+		q       string
+		want    string
+		wantErr bool
+	}{
+		{"hello", "hello", false},
+		{"hello world", "hello & world", false},
+		{"chirp*", "chirp:*", false},
+		{`"good morning" coffee`, "(good <-> morning) & coffee", false},
+		{`"good morn*`, "(good <-> morn:*)", false},
+		{"e-mail", "(e <-> mail)", false},
+		{"it's & (fine) | !bad:*", "(it <-> s) & fine & bad:*", false},
+		{`  "" *&! `, "", true},
+	}
+	for _, tt := range tests {
+		got, err := buildSearchQuery(tt.q)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("buildSearchQuery(%q) = %q, %v, want %q, error %v", tt.q, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func searchRows(results ...database.Chirp) *sqlmock.Rows {
+	rows := sqlmock.NewRows(append(chirpColumns, "rank", "snippet"))
+	for i, chirp := range results {
+		rows.AddRow(append(chirpValues(chirp), float32(0.5)/float32(i+1), chirp.Body)...)
+	}
+	return rows
+}
+
+func TestSearchChirpsPagesAndDecorates(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	original := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "hello world", UserID: uuid.New()}
+	quote := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "hello again", UserID: uuid.New(),
+		QuoteOfID: uuid.NullUUID{UUID: original.ID, Valid: true}}
+	other := database.Chirp{ID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now(), Body: "hello", UserID: uuid.New()}
+
+	mock.ExpectQuery("SearchChirps").WithArgs("hello", nil, nil, nil, nil, 2).WillReturnRows(searchRows(quote, other))
+	mock.ExpectQuery("GetChirpsByIds").WillReturnRows(sqlmock.NewRows(chirpColumns).AddRow(chirpValues(original)...))
+	rec := httptest.NewRecorder()
+	cfg.handlerSearchChirps(rec, httptest.NewRequest(http.MethodGet, "/api/chirps/search?q=hello&limit=1", nil))
+
+	var got []ChirpSearchResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil || rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body)
+	}
+	if len(got) != 1 || got[0].Id != quote.ID || got[0].Original == nil || got[0].Original.Id != original.ID {
+		t.Fatalf("got %+v, want the quote with its original", got)
+	}
+
+	link := rec.Header().Get("Link")
+	start, end := strings.Index(link, "<"), strings.Index(link, ">")
+	if start < 0 || end < start {
+		t.Fatalf("Link = %q, want a next page", link)
+	}
+	next, err := url.Parse(link[start+1 : end])
+	if err != nil {
+		t.Fatal(err)
+	}
+	cursor, err := parsePageCursor(next.Query().Get("cursor"))
+	if err != nil || cursor.ID != quote.ID || cursor.Rank != got[0].Rank {
+		t.Fatalf("next cursor = %+v, %v, want the quote's rank and id", cursor, err)
+	}
+
+	// The next page continues below the last result's rank.
+	mock.ExpectQuery("SearchChirps").WithArgs("hello", nil, float64(cursor.Rank), sqlmock.AnyArg(), quote.ID, 2).
+		WillReturnRows(searchRows(other))
+	rec = httptest.NewRecorder()
+	cfg.handlerSearchChirps(rec, httptest.NewRequest(http.MethodGet, next.RequestURI(), nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Link") != "" {
+		t.Errorf("got %d with Link %q, want the last page", rec.Code, rec.Header().Get("Link"))
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+type Session struct {
+	Id         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UserAgent  string     `json:"user_agent"`
+	IpAddress  string     `json:"ip_address"`
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func (cfg *apiConfig) handlerListSessions(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	sessions, err := cfg.db.ListSessionsForUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get sessions from db", err)
+		return
+	}
+
+	responseBody := []Session{}
+	for _, session := range sessions {
+		responseBody = append(responseBody, sessionFromRow(session))
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
+
+func (cfg *apiConfig) handlerRevokeSession(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	sessionId, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid session id", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokeSession(context.Background(), database.RevokeSessionParams{
+		FamilyID: sessionId,
+		UserID:   userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to revoke session", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "session not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) handlerRevokeAllSessions(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	err := cfg.db.RevokeAllSessionsForUser(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to revoke sessions", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func sessionFromRow(row database.ListSessionsForUserRow) Session {
+	session := Session{
+		Id:        row.FamilyID,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+		UserAgent: row.UserAgent,
+		IpAddress: row.IpAddress,
+	}
+	if row.LastUsedAt.Valid {
+		session.LastUsedAt = &row.LastUsedAt.Time
+	}
+	return session
+}
@@ -0,0 +1,21 @@
+-- name: BookmarkChirp :exec
+INSERT INTO chirp_bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (user_id, chirp_id) DO NOTHING;
+
+-- name: DeleteBookmark :exec
+DELETE FROM chirp_bookmarks
+WHERE user_id = $1 AND chirp_id = $2
+;
+
+-- name: ListBookmarkedChirps :many
+SELECT chirps.*, chirp_bookmarks.created_at AS bookmarked_at
+FROM chirp_bookmarks
+JOIN chirps ON chirps.id = chirp_bookmarks.chirp_id
+WHERE chirp_bookmarks.user_id = $1
+    AND chirps.deleted_at IS NULL
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (chirp_bookmarks.created_at, chirp_bookmarks.chirp_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY chirp_bookmarks.created_at DESC, chirp_bookmarks.chirp_id DESC
+LIMIT sqlc.arg(max_results)
+;
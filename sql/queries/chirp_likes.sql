@@ -0,0 +1,37 @@
+-- name: LikeChirp :exec
+INSERT INTO chirp_likes (chirp_id, user_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
+
+-- name: UnlikeChirp :exec
+DELETE FROM chirp_likes
+WHERE chirp_id = $1 AND user_id = $2
+;
+
+-- name: ListChirpLikes :many
+SELECT user_id, created_at
+FROM chirp_likes
+WHERE chirp_id = $1
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, user_id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, user_id ASC
+LIMIT sqlc.arg(max_results)
+;
+
+-- name: ListLikedChirps :many
+SELECT chirps.*, chirp_likes.created_at AS liked_at
+FROM chirp_likes
+JOIN chirps ON chirps.id = chirp_likes.chirp_id
+WHERE chirp_likes.user_id = $1
+    AND chirps.deleted_at IS NULL
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (chirp_likes.created_at, chirp_likes.chirp_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY chirp_likes.created_at DESC, chirp_likes.chirp_id DESC
+LIMIT sqlc.arg(max_results)
+;
+
+-- name: GetLikedChirpIds :many
+SELECT chirp_id
+FROM chirp_likes
+WHERE user_id = $1 AND chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+;
@@ -1,36 +1,202 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id, quote_of_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
+
+-- name: CreateRechirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, rechirp_of_id)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    '',
+    $1,
     $2
 )
+ON CONFLICT (user_id, rechirp_of_id) WHERE rechirp_of_id IS NOT NULL AND deleted_at IS NULL DO NOTHING
 RETURNING *;
 
+-- name: DeleteRechirp :execrows
+DELETE FROM chirps
+WHERE user_id = $1 AND rechirp_of_id = $2 AND deleted_at IS NULL
+;
+
+-- name: GetChirpsByIds :many
+SELECT *
+FROM chirps
+WHERE id = ANY(sqlc.arg(ids)::uuid[])
+;
+
 -- name: DeleteChirps :exec
 DELETE FROM chirps;
 
--- name: DeleteChripById :exec
+-- name: SoftDeleteChirp :execrows
+UPDATE chirps
+SET deleted_at = NOW(), deleted_by = $2
+WHERE id = $1 AND deleted_at IS NULL
+;
+
+-- name: GetChirpById :one
+SELECT *
+FROM chirps
+WHERE id = $1 AND deleted_at IS NULL
+;
+
+-- name: GetDeletedChirp :one
+SELECT *
+FROM chirps
+WHERE id = $1 AND deleted_at IS NOT NULL
+;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL, deleted_by = NULL
+WHERE id = $1 AND deleted_at > sqlc.arg(deleted_after)
+RETURNING *;
+
+-- name: ListDeletedChirps :many
+SELECT *
+FROM chirps
+WHERE user_id = $1 AND deleted_by = user_id AND deleted_at > sqlc.arg(deleted_after)
+ORDER BY deleted_at DESC
+;
+
+-- name: PurgeDeletedChirps :execrows
 DELETE FROM chirps
-WHERE id = $1 and user_id = $2
+WHERE deleted_at < sqlc.arg(deleted_before)
+    -- Chirps that are replied to, rechirped or quoted are only scrubbed, so
+    -- the others' chirps keep a placeholder and go through their own trash.
+    AND NOT EXISTS (SELECT 1 FROM chirps AS replies WHERE replies.reply_to_id = chirps.id)
+    AND NOT EXISTS (SELECT 1 FROM chirps AS rechirps WHERE rechirps.rechirp_of_id = chirps.id)
+    AND NOT EXISTS (SELECT 1 FROM chirps AS quotes WHERE quotes.quote_of_id = chirps.id)
+;
+
+-- name: ScrubDeletedChirps :execrows
+WITH scrubbed AS (
+    UPDATE chirps
+    SET body = ''
+    WHERE deleted_at < sqlc.arg(deleted_before) AND body <> ''
+    RETURNING id
+), unindexed AS (
+    DELETE FROM chirp_hashtags
+    WHERE chirp_id IN (SELECT id FROM scrubbed)
+), unbookmarked AS (
+    DELETE FROM chirp_bookmarks
+    WHERE chirp_id IN (SELECT id FROM scrubbed)
+)
+DELETE FROM chirp_revisions
+WHERE chirp_id IN (SELECT id FROM scrubbed)
 ;
 
--- name: GetAllChirps :many
+-- name: ListChirps :many
 SELECT *
 FROM chirps
-ORDER BY created_at ASC
+WHERE deleted_at IS NULL
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+    AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) > (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at ASC, id ASC
+LIMIT sqlc.arg(max_results)
 ;
 
--- name: GetChirpById :one
+-- name: ListChirpsDesc :many
 SELECT *
 FROM chirps
-WHERE id = $1
+WHERE deleted_at IS NULL
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+    AND (sqlc.narg(since)::timestamp IS NULL OR created_at >= sqlc.narg(since))
+    AND (sqlc.narg(until)::timestamp IS NULL OR created_at < sqlc.narg(until))
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (created_at, id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY created_at DESC, id DESC
+LIMIT sqlc.arg(max_results)
 ;
 
--- name: GetAllChirpsForUser :many
+-- name: SearchChirps :many
+SELECT chirps.*,
+    ts_rank(search_vector, query) AS rank,
+    ts_headline(
+        'english',
+        replace(replace(replace(body, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+        query,
+        'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'
+    ) AS snippet
+FROM chirps, to_tsquery('english', sqlc.arg(query)) query
+WHERE search_vector @@ query
+    AND deleted_at IS NULL
+    AND (sqlc.narg(author_id)::uuid IS NULL OR user_id = sqlc.narg(author_id))
+    AND (sqlc.narg(cursor_rank)::real IS NULL
+        OR (ts_rank(search_vector, query), created_at, id)
+            < (sqlc.narg(cursor_rank), sqlc.narg(cursor_created_at)::timestamp, sqlc.narg(cursor_id)::uuid))
+ORDER BY rank DESC, created_at DESC, id DESC
+LIMIT sqlc.arg(max_results)
+;
+
+-- name: EditChirp :one
+WITH previous AS (
+    INSERT INTO chirp_revisions (id, chirp_id, body, created_at, replaced_at)
+    SELECT gen_random_uuid(), id, body, updated_at, NOW()
+    FROM chirps
+    WHERE id = $1 AND user_id = $2 AND deleted_at IS NULL
+    FOR UPDATE
+    RETURNING chirp_id
+)
+UPDATE chirps
+SET body = $3, updated_at = NOW(), revision_count = revision_count + 1
+FROM previous
+WHERE chirps.id = previous.chirp_id
+RETURNING chirps.*;
+
+-- name: GetChirpRevisions :many
 SELECT *
+FROM chirp_revisions
+WHERE chirp_id = $1
+ORDER BY replaced_at ASC
+;
+
+-- name: GetChirpAncestors :many
+WITH RECURSIVE ancestors (id, reply_to_id, depth) AS (
+    SELECT id, reply_to_id, 0
+    FROM chirps
+    WHERE id = $1
+    UNION ALL
+    SELECT chirps.id, chirps.reply_to_id, ancestors.depth + 1
+    FROM chirps
+    JOIN ancestors ON chirps.id = ancestors.reply_to_id
+)
+SELECT chirps.*
+FROM chirps
+JOIN ancestors ON chirps.id = ancestors.id
+WHERE ancestors.depth > 0
+ORDER BY ancestors.depth DESC
+;
+
+-- name: GetReplyTree :many
+WITH RECURSIVE replies (id, depth, path) AS (
+    -- path orders the tree depth first, and each level by (created_at, id).
+    SELECT id, 1, int8send((extract(epoch FROM created_at) * 1000000)::bigint) || uuid_send(id)
+    FROM chirps
+    WHERE reply_to_id = $1
+    UNION ALL
+    SELECT chirps.id, replies.depth + 1,
+        replies.path || int8send((extract(epoch FROM chirps.created_at) * 1000000)::bigint) || uuid_send(chirps.id)
+    FROM chirps
+    JOIN replies ON chirps.reply_to_id = replies.id
+)
+SELECT chirps.*, replies.depth, replies.path
 FROM chirps
-WHERE user_id = $1
-;
\ No newline at end of file
+JOIN replies ON chirps.id = replies.id
+WHERE sqlc.narg(after_path)::bytea IS NULL OR replies.path > sqlc.narg(after_path)
+ORDER BY replies.path
+LIMIT sqlc.arg(max_results)
+;
@@ -0,0 +1,50 @@
+-- name: SetChirpHashtags :exec
+WITH chirp_tags AS (
+    -- DO UPDATE rather than DO NOTHING so that a tag inserted concurrently
+    -- by another chirp still returns its id. The tags are distinct, as the
+    -- same row can't be updated twice in one statement.
+    INSERT INTO hashtags (id, tag, created_at)
+    SELECT gen_random_uuid(), tag, NOW()
+    FROM unnest(sqlc.arg(tags)::text[]) AS tag
+    ON CONFLICT (tag) DO UPDATE SET tag = EXCLUDED.tag
+    RETURNING id
+), removed AS (
+    DELETE FROM chirp_hashtags
+    WHERE chirp_id = sqlc.arg(chirp_id)
+        AND hashtag_id NOT IN (SELECT id FROM chirp_tags)
+)
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+SELECT sqlc.arg(chirp_id), id, sqlc.arg(created_at)::timestamp
+FROM chirp_tags
+ON CONFLICT (chirp_id, hashtag_id) DO NOTHING;
+
+-- name: ListHashtagChirps :many
+SELECT chirps.*
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE hashtags.tag = $1
+    AND chirps.deleted_at IS NULL
+    AND (sqlc.narg(cursor_created_at)::timestamp IS NULL
+        OR (chirp_hashtags.created_at, chirp_hashtags.chirp_id) < (sqlc.narg(cursor_created_at), sqlc.narg(cursor_id)::uuid))
+ORDER BY chirp_hashtags.created_at DESC, chirp_hashtags.chirp_id DESC
+LIMIT sqlc.arg(max_results)
+;
+
+-- name: GetTrendingHashtags :many
+SELECT
+    hashtags.tag,
+    COUNT(*) AS chirp_count,
+    -- Each chirp counts for half as much every half life, and not at all
+    -- once it is older than the window.
+    SUM(power(0.5, extract(epoch FROM LOCALTIMESTAMP - chirp_hashtags.created_at)::float8
+        / sqlc.arg(half_life_seconds)::float8))::float8 AS score
+FROM chirp_hashtags
+JOIN hashtags ON hashtags.id = chirp_hashtags.hashtag_id
+JOIN chirps ON chirps.id = chirp_hashtags.chirp_id
+WHERE chirp_hashtags.created_at > LOCALTIMESTAMP - make_interval(secs => sqlc.arg(window_seconds)::float8)
+    AND chirps.deleted_at IS NULL
+GROUP BY hashtags.tag
+ORDER BY score DESC, hashtags.tag ASC
+LIMIT sqlc.arg(max_results)
+;
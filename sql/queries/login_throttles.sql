@@ -0,0 +1,31 @@
+-- name: GetLoginThrottles :many
+SELECT *
+FROM login_throttles
+WHERE key = ANY(@keys::text[])
+;
+
+-- name: RecordLoginFailure :one
+INSERT INTO login_throttles (key, failures, last_failure_at)
+VALUES (
+    $1,
+    1,
+    NOW()
+)
+ON CONFLICT (key) DO UPDATE
+SET failures = CASE
+        WHEN login_throttles.last_failure_at < NOW() - INTERVAL '1 hour' THEN 1
+        ELSE login_throttles.failures + 1
+    END,
+    last_failure_at = NOW()
+RETURNING *;
+
+-- name: LockLogin :exec
+UPDATE login_throttles
+SET locked_until = $2
+WHERE key = $1
+;
+
+-- name: ClearLoginThrottles :exec
+DELETE FROM login_throttles
+WHERE key = ANY(@keys::text[])
+;
@@ -0,0 +1,13 @@
+-- name: UseMagicLink :execrows
+INSERT INTO used_magic_links (token_id, expires_at, used_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (token_id) DO NOTHING;
+
+-- name: DeleteExpiredMagicLinks :exec
+DELETE FROM used_magic_links
+WHERE expires_at < NOW()
+;
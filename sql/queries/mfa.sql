@@ -0,0 +1,50 @@
+-- name: CreatePendingTotpCredential :execrows
+INSERT INTO totp_credentials (user_id, encrypted_secret, created_at)
+VALUES (
+    $1,
+    $2,
+    NOW()
+)
+ON CONFLICT (user_id) DO UPDATE
+SET encrypted_secret = EXCLUDED.encrypted_secret, created_at = NOW(), last_used_step = 0
+WHERE totp_credentials.confirmed_at IS NULL
+;
+
+-- name: GetTotpCredential :one
+SELECT *
+FROM totp_credentials
+WHERE user_id = $1
+;
+
+-- name: ConfirmTotpCredential :exec
+UPDATE totp_credentials
+SET confirmed_at = NOW()
+WHERE user_id = $1
+;
+
+-- name: UseTotpStep :execrows
+UPDATE totp_credentials
+SET last_used_step = $2
+WHERE user_id = $1 AND last_used_step < $2
+;
+
+-- name: DeleteTotpCredential :exec
+DELETE FROM totp_credentials
+WHERE user_id = $1
+;
+
+-- name: CreateRecoveryCodes :exec
+INSERT INTO mfa_recovery_codes (code_hash, user_id, created_at)
+SELECT unnest(@code_hashes::text[]), @user_id::uuid, NOW()
+;
+
+-- name: UseRecoveryCode :execrows
+UPDATE mfa_recovery_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND user_id = $2 AND used_at IS NULL
+;
+
+-- name: DeleteRecoveryCodes :exec
+DELETE FROM mfa_recovery_codes
+WHERE user_id = $1
+;
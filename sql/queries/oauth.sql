@@ -0,0 +1,101 @@
+-- name: CreateOauthClient :one
+INSERT INTO oauth_clients (id, secret_hash, name, redirect_uris, owner_id, created_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    NOW()
+)
+RETURNING *;
+
+-- name: GetOauthClient :one
+SELECT *
+FROM oauth_clients
+WHERE id = $1
+;
+
+-- name: ListOauthClientsForOwner :many
+SELECT *
+FROM oauth_clients
+WHERE owner_id = $1
+ORDER BY created_at DESC
+;
+
+-- name: DeleteOauthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = $1 AND owner_id = $2
+;
+
+-- name: CreateOauthAuthorizationCode :exec
+INSERT INTO oauth_authorization_codes (code_hash, grant_id, client_id, user_id, redirect_uri, scopes, code_challenge, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    NOW(),
+    $8
+);
+
+-- name: ConsumeOauthAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = NOW()
+WHERE code_hash = $1 AND client_id = $2
+    -- An empty redirect_uri was left out of the authorization request.
+    AND (redirect_uri = '' OR redirect_uri = $3)
+    AND used_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: GetOauthAuthorizationCode :one
+SELECT *
+FROM oauth_authorization_codes
+WHERE code_hash = $1
+;
+
+-- name: CreateOauthToken :exec
+INSERT INTO oauth_tokens (token_hash, token_type, grant_id, client_id, user_id, scopes, created_at, expires_at)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    NOW(),
+    $7
+);
+
+-- name: GetOauthToken :one
+SELECT *
+FROM oauth_tokens
+WHERE token_hash = $1
+;
+
+-- name: GetActiveOauthToken :one
+SELECT *
+FROM oauth_tokens
+WHERE token_hash = $1 AND revoked_at IS NULL AND expires_at > NOW()
+;
+
+-- name: RotateOauthRefreshToken :one
+UPDATE oauth_tokens
+SET revoked_at = NOW()
+WHERE token_hash = $1 AND token_type = 'refresh_token' AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeOauthToken :exec
+UPDATE oauth_tokens
+SET revoked_at = NOW()
+WHERE token_hash = $1 AND revoked_at IS NULL
+;
+
+-- name: RevokeOauthGrant :exec
+UPDATE oauth_tokens
+SET revoked_at = NOW()
+WHERE grant_id = $1 AND revoked_at IS NULL
+;
@@ -0,0 +1,48 @@
+-- name: RoleExists :one
+SELECT EXISTS (
+    SELECT 1
+    FROM roles
+    WHERE name = $1
+);
+
+-- name: GetUserRoles :many
+SELECT role
+FROM user_roles
+WHERE user_id = $1
+ORDER BY role
+;
+
+-- name: GetUserPermissions :many
+SELECT DISTINCT permission
+FROM role_permissions
+WHERE role = 'user' OR role IN (
+    SELECT role
+    FROM user_roles
+    WHERE user_id = $1
+)
+ORDER BY permission
+;
+
+-- name: GrantRole :exec
+INSERT INTO user_roles (user_id, role, granted_at, granted_by)
+VALUES (
+    $1,
+    $2,
+    NOW(),
+    $3
+)
+ON CONFLICT (user_id, role) DO NOTHING;
+
+-- name: GrantRoleByEmail :execrows
+INSERT INTO user_roles (user_id, role, granted_at, granted_by)
+SELECT id, $2, NOW(), NULL
+FROM users
+-- Anyone can sign up with, or change their email to, an address they
+-- don't own, so only a verified address is trusted with admin.
+WHERE email = $1 AND email_verified_at IS NOT NULL
+ON CONFLICT (user_id, role) DO NOTHING;
+
+-- name: RevokeRole :execrows
+DELETE FROM user_roles
+WHERE user_id = $1 AND role = $2
+;
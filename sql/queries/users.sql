@@ -9,6 +9,18 @@ VALUES (
 )
 RETURNING *;
 
+-- name: CreatePasswordlessUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, email_verified_at)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    NULL,
+    NOW()
+)
+RETURNING *;
+
 -- name: DeleteUsers :exec
 DELETE FROM users;
 
@@ -26,7 +38,8 @@ where email = $1
 
 -- name: UpdateUser :one
 UPDATE users
-SET email = $2, hashed_password = $3
+SET email = $2, hashed_password = $3,
+    email_verified_at = CASE WHEN email = $2 THEN email_verified_at ELSE NULL END
 WHERE id = $1
 RETURNING *;
 
@@ -34,4 +47,60 @@ RETURNING *;
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
-;
\ No newline at end of file
+;
+
+-- name: UpdateUserPassword :exec
+UPDATE users
+SET hashed_password = $2, updated_at = NOW()
+WHERE id = $1
+;
+
+-- name: VerifyUserEmail :execrows
+UPDATE users
+SET email_verified_at = COALESCE(email_verified_at, NOW())
+WHERE id = $1 AND email = $2
+;
+
+-- name: ClaimUnverifiedUser :one
+WITH claimed AS (
+    -- Whoever signed up with an address that was never verified may not own
+    -- it, so everything that lets them back into the account goes in the
+    -- same statement that verifies it.
+    UPDATE users
+    SET hashed_password = NULL, email_verified_at = NOW(), updated_at = NOW()
+    WHERE id = $1 AND email = $2 AND email_verified_at IS NULL
+    RETURNING id
+), sessions AS (
+    UPDATE refresh_tokens
+    SET updated_at = NOW(), revoked_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND revoked_at IS NULL
+), pats AS (
+    UPDATE personal_access_tokens
+    SET revoked_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND revoked_at IS NULL
+), grants AS (
+    UPDATE oauth_tokens
+    SET revoked_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND revoked_at IS NULL
+), codes AS (
+    DELETE FROM oauth_authorization_codes
+    WHERE user_id IN (SELECT id FROM claimed)
+), clients AS (
+    DELETE FROM oauth_clients
+    WHERE owner_id IN (SELECT id FROM claimed)
+), totp AS (
+    DELETE FROM totp_credentials
+    WHERE user_id IN (SELECT id FROM claimed)
+), recovery_codes AS (
+    DELETE FROM mfa_recovery_codes
+    WHERE user_id IN (SELECT id FROM claimed)
+), resets AS (
+    UPDATE password_reset_tokens
+    SET used_at = NOW()
+    WHERE user_id IN (SELECT id FROM claimed) AND used_at IS NULL
+), identities AS (
+    -- The caller links the identity it is claiming with afterwards.
+    DELETE FROM user_identities
+    WHERE user_id IN (SELECT id FROM claimed)
+)
+SELECT id FROM claimed;
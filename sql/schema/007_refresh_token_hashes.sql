@@ -0,0 +1,10 @@
+-- +goose Up
+-- Existing tokens are hashed with plain SHA-256 here; the application
+-- upgrades them to its keyed hash the next time they are used.
+UPDATE refresh_tokens SET token = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+ALTER TABLE refresh_tokens RENAME COLUMN token TO token_hash;
+
+-- +goose Down
+-- Hashes cannot be turned back into tokens, so every session is dropped.
+DELETE FROM refresh_tokens;
+ALTER TABLE refresh_tokens RENAME COLUMN token_hash TO token;
\ No newline at end of file
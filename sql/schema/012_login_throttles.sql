@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE login_throttles (
+    key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ DEFAULT NULL
+);
+
+-- +goose Down
+DROP TABLE login_throttles;
\ No newline at end of file
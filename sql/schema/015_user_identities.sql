@@ -0,0 +1,18 @@
+-- +goose Up
+ALTER TABLE users ALTER COLUMN hashed_password DROP NOT NULL;
+
+CREATE TABLE user_identities (
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    email TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL,
+    last_login_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (issuer, subject)
+);
+CREATE INDEX user_identities_user_id_idx ON user_identities (user_id);
+
+-- +goose Down
+DROP TABLE user_identities;
+UPDATE users SET hashed_password = '' WHERE hashed_password IS NULL;
+ALTER TABLE users ALTER COLUMN hashed_password SET NOT NULL;
\ No newline at end of file
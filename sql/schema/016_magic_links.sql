@@ -0,0 +1,11 @@
+-- +goose Up
+-- Magic link tokens are signed, not stored; only their ids are recorded once
+-- used so that each link works a single time.
+CREATE TABLE used_magic_links (
+    token_id UUID PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ NOT NULL
+);
+
+-- +goose Down
+DROP TABLE used_magic_links;
\ No newline at end of file
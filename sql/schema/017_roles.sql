@@ -0,0 +1,51 @@
+-- +goose Up
+CREATE TABLE roles (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE permissions (
+    name TEXT PRIMARY KEY,
+    description TEXT NOT NULL
+);
+
+CREATE TABLE role_permissions (
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    permission TEXT NOT NULL REFERENCES permissions(name) ON DELETE CASCADE,
+    PRIMARY KEY (role, permission)
+);
+
+-- Every user implicitly has the user role; only other roles are stored.
+CREATE TABLE user_roles (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role TEXT NOT NULL REFERENCES roles(name) ON DELETE CASCADE,
+    granted_at TIMESTAMPTZ NOT NULL,
+    granted_by UUID REFERENCES users(id) ON DELETE SET NULL,
+    PRIMARY KEY (user_id, role)
+);
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Every signed up user'),
+    ('moderator', 'Moderates chirps posted by other users'),
+    ('admin', 'Operates Chirpy');
+
+INSERT INTO permissions (name, description) VALUES
+    ('chirps:moderate', 'Delete chirps posted by other users'),
+    ('metrics:read', 'View server metrics'),
+    ('users:unlock', 'Clear login lockouts'),
+    ('roles:manage', 'Grant and revoke roles'),
+    ('system:reset', 'Delete every user and chirp');
+
+INSERT INTO role_permissions (role, permission) VALUES
+    ('moderator', 'chirps:moderate'),
+    ('admin', 'chirps:moderate'),
+    ('admin', 'metrics:read'),
+    ('admin', 'users:unlock'),
+    ('admin', 'roles:manage'),
+    ('admin', 'system:reset');
+
+-- +goose Down
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE permissions;
+DROP TABLE roles;
\ No newline at end of file
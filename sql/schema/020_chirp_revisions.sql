@@ -0,0 +1,17 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN revision_count INTEGER NOT NULL DEFAULT 0;
+
+-- Every edit keeps the body it replaced. created_at is when that body was
+-- written, the chirp's updated_at at the time of the edit.
+CREATE TABLE chirp_revisions (
+    id UUID PRIMARY KEY,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    replaced_at TIMESTAMP NOT NULL
+);
+CREATE INDEX chirp_revisions_chirp_id_idx ON chirp_revisions (chirp_id, replaced_at);
+
+-- +goose Down
+DROP TABLE chirp_revisions;
+ALTER TABLE chirps DROP COLUMN revision_count;
\ No newline at end of file
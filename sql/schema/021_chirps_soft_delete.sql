@@ -0,0 +1,12 @@
+-- +goose Up
+-- Deleted chirps are kept as tombstones until they are purged, recording who
+-- deleted them: the author or a moderator.
+ALTER TABLE chirps
+    ADD COLUMN deleted_at TIMESTAMP,
+    ADD COLUMN deleted_by UUID REFERENCES users(id) ON DELETE SET NULL;
+CREATE INDEX chirps_deleted_at_idx ON chirps (deleted_at) WHERE deleted_at IS NOT NULL;
+
+-- +goose Down
+DELETE FROM chirps WHERE deleted_at IS NOT NULL;
+DROP INDEX chirps_deleted_at_idx;
+ALTER TABLE chirps DROP COLUMN deleted_by, DROP COLUMN deleted_at;
\ No newline at end of file
@@ -0,0 +1,30 @@
+-- +goose Up
+ALTER TABLE chirps
+    ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    ADD COLUMN reply_count INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX chirps_reply_to_id_idx ON chirps (reply_to_id, created_at, id);
+
+-- reply_count counts the replies that haven't been deleted.
+-- +goose StatementBegin
+CREATE FUNCTION update_chirp_reply_count() RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP IN ('INSERT', 'UPDATE') AND NEW.reply_to_id IS NOT NULL AND NEW.deleted_at IS NULL THEN
+        UPDATE chirps SET reply_count = reply_count + 1 WHERE id = NEW.reply_to_id;
+    END IF;
+    IF TG_OP IN ('UPDATE', 'DELETE') AND OLD.reply_to_id IS NOT NULL AND OLD.deleted_at IS NULL THEN
+        UPDATE chirps SET reply_count = reply_count - 1 WHERE id = OLD.reply_to_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_reply_count
+AFTER INSERT OR DELETE OR UPDATE OF deleted_at ON chirps
+FOR EACH ROW EXECUTE FUNCTION update_chirp_reply_count();
+
+-- +goose Down
+DROP TRIGGER chirps_reply_count ON chirps;
+DROP FUNCTION update_chirp_reply_count;
+DROP INDEX chirps_reply_to_id_idx;
+ALTER TABLE chirps DROP COLUMN reply_count, DROP COLUMN reply_to_id;
\ No newline at end of file
@@ -0,0 +1,17 @@
+-- +goose Up
+-- A rechirp reposts another chirp as is and has no body of its own; a quote
+-- chirp adds a body.
+ALTER TABLE chirps
+    ADD COLUMN rechirp_of_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    ADD COLUMN quote_of_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    ADD CONSTRAINT chirps_rechirp_or_quote CHECK (rechirp_of_id IS NULL OR quote_of_id IS NULL);
+CREATE UNIQUE INDEX chirps_user_id_rechirp_of_id_idx ON chirps (user_id, rechirp_of_id)
+    WHERE rechirp_of_id IS NOT NULL AND deleted_at IS NULL;
+
+-- +goose Down
+DELETE FROM chirps WHERE rechirp_of_id IS NOT NULL;
+DROP INDEX chirps_user_id_rechirp_of_id_idx;
+ALTER TABLE chirps
+    DROP CONSTRAINT chirps_rechirp_or_quote,
+    DROP COLUMN quote_of_id,
+    DROP COLUMN rechirp_of_id;
\ No newline at end of file
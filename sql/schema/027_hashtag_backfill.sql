@@ -0,0 +1,29 @@
+-- +goose Up
+-- Index the chirps posted before hashtags were. This follows
+-- extractHashtags: a tag starts after anything that can't be part of a word,
+-- is lower cased, and is skipped if it has no letters or contains a censored
+-- word.
+CREATE TEMPORARY TABLE backfilled_hashtags AS
+SELECT DISTINCT chirps.id AS chirp_id, chirps.created_at, lower(match[1]) AS tag
+FROM chirps,
+    regexp_matches(chirps.body, '(?:^|[^[:alnum:]_])#([[:alnum:]_*]+)', 'g') AS match
+WHERE chirps.body LIKE '%#%';
+
+DELETE FROM backfilled_hashtags
+WHERE tag LIKE '%*%' OR tag !~ '[[:alpha:]]';
+
+INSERT INTO hashtags (id, tag, created_at)
+SELECT gen_random_uuid(), tag, NOW()
+FROM (SELECT DISTINCT tag FROM backfilled_hashtags) AS tags
+ON CONFLICT (tag) DO NOTHING;
+
+INSERT INTO chirp_hashtags (chirp_id, hashtag_id, created_at)
+SELECT backfilled_hashtags.chirp_id, hashtags.id, backfilled_hashtags.created_at
+FROM backfilled_hashtags
+JOIN hashtags ON hashtags.tag = backfilled_hashtags.tag
+ON CONFLICT (chirp_id, hashtag_id) DO NOTHING;
+
+DROP TABLE backfilled_hashtags;
+
+-- +goose Down
+-- Backfilled hashtags can't be told apart from the others, so they are kept.
\ No newline at end of file
@@ -0,0 +1,8 @@
+-- +goose Up
+-- The purger checks whether a deleted chirp is still rechirped or quoted.
+CREATE INDEX chirps_rechirp_of_id_idx ON chirps (rechirp_of_id);
+CREATE INDEX chirps_quote_of_id_idx ON chirps (quote_of_id);
+
+-- +goose Down
+DROP INDEX chirps_quote_of_id_idx;
+DROP INDEX chirps_rechirp_of_id_idx;
\ No newline at end of file
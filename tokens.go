@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jpheneger/chirpy/internal/auth"
+	"github.com/jpheneger/chirpy/internal/database"
+)
+
+type PersonalAccessToken struct {
+	Id         uuid.UUID  `json:"id"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func (cfg *apiConfig) handlerCreateToken(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		Name      string     `json:"name"`
+		Scopes    []string   `json:"scopes"`
+		ExpiresAt *time.Time `json:"expires_at"`
+	}
+
+	// Only a logged in user, not another personal access token, may mint
+	// tokens.
+	userId := principalFrom(r.Context()).UserID
+
+	decoder := json.NewDecoder(r.Body)
+	reqBody := req{}
+	err := decoder.Decode(&reqBody)
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "Couldn't decode request body", err)
+		return
+	}
+	if reqBody.Name == "" {
+		respondWithError(w, http.StatusBadRequest, "token name is required", nil)
+		return
+	}
+	if len(reqBody.Scopes) == 0 {
+		respondWithError(w, http.StatusBadRequest, "at least one scope is required", nil)
+		return
+	}
+	for _, scope := range reqBody.Scopes {
+		if !auth.ValidScope(scope) {
+			respondWithError(w, http.StatusBadRequest, "unknown scope: "+scope, nil)
+			return
+		}
+	}
+	expiresAt := sql.NullTime{}
+	if reqBody.ExpiresAt != nil {
+		if reqBody.ExpiresAt.Before(time.Now()) {
+			respondWithError(w, http.StatusBadRequest, "expires_at must be in the future", nil)
+			return
+		}
+		expiresAt = sql.NullTime{Time: *reqBody.ExpiresAt, Valid: true}
+	}
+
+	token, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to create token", err)
+		return
+	}
+	pat, err := cfg.db.CreatePersonalAccessToken(context.Background(), database.CreatePersonalAccessTokenParams{
+		UserID:    userId,
+		Name:      reqBody.Name,
+		TokenHash: auth.HashToken(cfg.tokenHashKey, token),
+		Scopes:    reqBody.Scopes,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to store token", err)
+		return
+	}
+
+	// The token itself is only ever shown in this response.
+	responseBody := personalAccessTokenFromDB(pat)
+	responseBody.Token = token
+	respondWithJSON(w, http.StatusCreated, responseBody)
+}
+
+func (cfg *apiConfig) handlerListTokens(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	pats, err := cfg.db.ListPersonalAccessTokens(context.Background(), userId)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't get tokens from db", err)
+		return
+	}
+
+	responseBody := []PersonalAccessToken{}
+	for _, pat := range pats {
+		responseBody = append(responseBody, personalAccessTokenFromDB(pat))
+	}
+	respondWithJSON(w, http.StatusOK, responseBody)
+}
+
+func (cfg *apiConfig) handlerRevokeToken(w http.ResponseWriter, r *http.Request) {
+	userId := principalFrom(r.Context()).UserID
+
+	tokenId, err := uuid.Parse(r.PathValue("tokenID"))
+	if err != nil {
+		respondWithError(w, http.StatusBadRequest, "invalid token id", err)
+		return
+	}
+
+	revoked, err := cfg.db.RevokePersonalAccessToken(context.Background(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenId,
+		UserID: userId,
+	})
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to revoke token", err)
+		return
+	}
+	if revoked == 0 {
+		respondWithError(w, http.StatusNotFound, "token not found", nil)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func personalAccessTokenFromDB(pat database.PersonalAccessToken) PersonalAccessToken {
+	token := PersonalAccessToken{
+		Id:        pat.ID,
+		Name:      pat.Name,
+		Scopes:    pat.Scopes,
+		CreatedAt: pat.CreatedAt,
+	}
+	if pat.ExpiresAt.Valid {
+		token.ExpiresAt = &pat.ExpiresAt.Time
+	}
+	if pat.LastUsedAt.Valid {
+		token.LastUsedAt = &pat.LastUsedAt.Time
+	}
+	return token
+}
@@ -2,11 +2,11 @@ package main
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"log"
 	"net/http"
-	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,16 +19,28 @@ type reqBody struct {
 	Password string `json:"password"`
 }
 type respBody struct {
-	Id           uuid.UUID `json:"id"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	Email        string    `json:"email"`
-	AccessToken  string    `json:"token"`
-	RefreshToken string    `json:"refresh_token"`
-	IsChirpyRed  bool      `json:"is_chirpy_red"`
+	Id            uuid.UUID `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+	Email         string    `json:"email"`
+	AccessToken   string    `json:"token"`
+	RefreshToken  string    `json:"refresh_token"`
+	IsChirpyRed   bool      `json:"is_chirpy_red"`
+	EmailVerified bool      `json:"email_verified"`
 }
 
+// loginCompleter answers a request once its user has fully authenticated,
+// with a session for POST /api/login or a consent token for the OAuth
+// consent page.
+type loginCompleter func(w http.ResponseWriter, r *http.Request, user database.User)
+
 func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
+	cfg.passwordLogin(w, r, cfg.respondWithSession)
+}
+
+// passwordLogin checks an email and password, then either completes the login
+// or answers with a challenge for the second factor.
+func (cfg *apiConfig) passwordLogin(w http.ResponseWriter, r *http.Request, complete loginCompleter) {
 	decoder := json.NewDecoder(r.Body)
 	body := reqBody{}
 	err := decoder.Decode(&body)
@@ -37,50 +49,135 @@ func (cfg *apiConfig) handlerLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !cfg.checkLoginThrottle(w, accountThrottleKey(body.Email), ipThrottleKey(r)) {
+		return
+	}
+
 	user, err := cfg.db.GetUserByEmail(context.Background(), body.Email)
 	if err != nil {
+		cfg.recordLoginFailure(r, body.Email)
 		respondWithError(w, http.StatusUnauthorized, "Unable to authenticate", err)
 		return
 	}
 
-	success, err := auth.CheckPasswordHash(user.HashedPassword, body.Password)
+	// Users who signed up through an identity provider have no password
+	// until they set one.
+	if !user.HashedPassword.Valid {
+		cfg.recordLoginFailure(r, body.Email)
+		respondWithError(w, http.StatusUnauthorized, "Login failed", nil)
+		return
+	}
+	success, err := cfg.passwordHasher.Verify(user.HashedPassword.String, body.Password)
 	if !success || err != nil {
+		cfg.recordLoginFailure(r, body.Email)
 		respondWithError(w, http.StatusUnauthorized, "Login failed", err)
 		return
 	}
 
+	if cfg.passwordHasher.NeedsRehash(user.HashedPassword.String) {
+		cfg.rehashPassword(user, body.Password)
+	}
+
+	mfaEnabled, err := cfg.mfaEnabled(user.ID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch two-factor settings from db", err)
+		return
+	}
+	if mfaEnabled {
+		// Failures are only cleared once the second factor is verified too,
+		// or knowing the password would reset the lockout for code guesses.
+		cfg.respondWithMFAChallenge(w, user.ID)
+		return
+	}
+
+	cfg.clearLoginFailures(user.Email)
+	complete(w, r, user)
+}
+
+// rehashPassword upgrades a stored hash written by an older algorithm or
+// with older parameters. Failing to do so doesn't fail the login.
+func (cfg *apiConfig) rehashPassword(user database.User, password string) {
+	hpw, err := cfg.passwordHasher.Hash(password)
+	if err != nil {
+		log.Printf("unable to rehash password for user %s: %v", user.ID, err)
+		return
+	}
+	err = cfg.db.UpdateUserPassword(context.Background(), database.UpdateUserPasswordParams{
+		ID:             user.ID,
+		HashedPassword: sql.NullString{String: hpw, Valid: true},
+	})
+	if err != nil {
+		log.Printf("unable to store rehashed password for user %s: %v", user.ID, err)
+	}
+}
+
+// respondWithSession completes a login: it issues an access token and a
+// refresh token in a new token family.
+func (cfg *apiConfig) respondWithSession(w http.ResponseWriter, r *http.Request, user database.User) {
 	expiresIn := 1 * time.Hour
-	accessToken, err := auth.MakeJWT(user.ID, cfg.signingSecret, expiresIn)
+	accessToken, err := cfg.makeAccessToken(user.ID, expiresIn)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "lgoin failed due to access token error", err)
 		return
 	}
 
-	refreshToken, err := auth.MakeRefreshToken()
+	refreshToken, err := cfg.createRefreshToken(r, user.ID, uuid.New(), time.Now())
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "lgoin failed due to refresh token error", err)
+		respondWithError(w, http.StatusInternalServerError, "unable to store refresh token", err)
 		return
 	}
-	result, err := cfg.db.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
-		Token:     refreshToken,
-		CreatedAt: time.Now(),
+
+	respondWithJSON(w, http.StatusOK, respBody{
+		Id:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed.Bool,
+		EmailVerified: user.EmailVerifiedAt.Valid,
+		AccessToken:   accessToken,
+		RefreshToken:  refreshToken,
+	})
+}
+
+// createRefreshToken stores the hash of a new refresh token in the given
+// token family and returns the token itself. Every token issued by rotating a
+// refresh token shares its family, so reuse of any revoked member can revoke
+// the whole chain.
+//
+// The request's user agent and address are recorded for the sessions API, and
+// sessionStart is carried across rotations as the session's creation time.
+func (cfg *apiConfig) createRefreshToken(r *http.Request, userID, familyID uuid.UUID, sessionStart time.Time) (string, error) {
+	refreshToken, err := auth.MakeRefreshToken()
+	if err != nil {
+		return "", err
+	}
+	_, err = cfg.db.CreateRefreshToken(context.Background(), database.CreateRefreshTokenParams{
+		TokenHash: auth.HashToken(cfg.tokenHashKey, refreshToken),
+		CreatedAt: sessionStart,
 		UpdatedAt: time.Now(),
-		UserID:    user.ID,
+		UserID:    userID,
 		ExpiresAt: time.Now().AddDate(0, 0, 60),
+		FamilyID:  familyID,
+		UserAgent: r.UserAgent(),
+		IpAddress: clientIP(r),
 	})
 	if err != nil {
-		respondWithError(w, http.StatusInternalServerError, "unable to store refresh token", err)
-		return
+		return "", err
 	}
+	return refreshToken, nil
+}
 
-	respondWithJSON(w, http.StatusOK, respBody{
-		Id:           user.ID,
-		CreatedAt:    user.CreatedAt,
-		UpdatedAt:    user.UpdatedAt,
-		Email:        user.Email,
-		IsChirpyRed:  user.IsChirpyRed.Bool,
-		AccessToken:  accessToken,
-		RefreshToken: result.Token,
+// getRefreshToken looks a refresh token up by its keyed hash, falling back to
+// the unkeyed hash of tokens issued before keyed hashing and upgrading them.
+func (cfg *apiConfig) getRefreshToken(ctx context.Context, token string) (database.RefreshToken, error) {
+	hash := auth.HashToken(cfg.tokenHashKey, token)
+	refreshToken, err := cfg.db.GetRefreshToken(ctx, hash)
+	if !errors.Is(err, sql.ErrNoRows) {
+		return refreshToken, err
+	}
+	return cfg.db.UpgradeRefreshTokenHash(ctx, database.UpgradeRefreshTokenHashParams{
+		NewHash:    hash,
+		LegacyHash: auth.LegacyTokenHash(token),
 	})
 }
 
@@ -93,53 +190,81 @@ func (cfg *apiConfig) handlerUsers(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	hpw, err := auth.HashPassword(body.Password)
+	hpw, err := cfg.passwordHasher.Hash(body.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Unable to hash password", err)
 		return
 	}
 	user, err := cfg.db.CreateUser(context.Background(), database.CreateUserParams{
 		Email:          body.Email,
-		HashedPassword: hpw,
+		HashedPassword: sql.NullString{String: hpw, Valid: true},
 	})
-	if err != nil {
-		log.Fatalf("unable to create user: %v", err)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "email is already in use", err)
+		return
+	} else if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "unable to create user", err)
 		return
 	}
 
+	err = cfg.sendVerificationEmail(user)
+	if err != nil {
+		log.Printf("unable to send verification email to %s: %v", user.Email, err)
+	}
+
 	respondWithJSON(w, http.StatusCreated, respBody{
-		Id:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed.Bool,
+		Id:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed.Bool,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	})
 }
 
+// handlerUpdateUser changes the caller's email address and password. Both
+// are credentials, so the current password is required as well as the
+// profile:write scope; a leaked token alone can't take over the account.
+// Users without a password can only set one from a login session.
 func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request) {
+	type req struct {
+		reqBody
+		CurrentPassword string `json:"current_password"`
+	}
+
 	decoder := json.NewDecoder(r.Body)
-	body := reqBody{}
+	body := req{}
 	err := decoder.Decode(&body)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Couldn't decode parameters", err)
 		return
 	}
 
-	authorization := r.Header.Get("Authorization")
-	if authorization == "" {
-		respondWithError(w, http.StatusUnauthorized, "no authorization header found", errors.New("no authorization header provided"))
-		return
-	}
-	token := strings.Split(authorization, "Bearer ")[1]
+	p := principalFrom(r.Context())
+	userID := p.UserID
 
-	userID, err := auth.ValidateJWT(token, cfg.signingSecret)
+	previous, err := cfg.db.GetUserById(context.Background(), userID)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "token invalid", err)
+		respondWithError(w, http.StatusInternalServerError, "Couldn't fetch user from db", err)
+		return
+	}
+	if previous.HashedPassword.Valid {
+		// Guessing the current password counts against the login lockout.
+		if !cfg.checkLoginThrottle(w, accountThrottleKey(previous.Email), ipThrottleKey(r)) {
+			return
+		}
+		success, err := cfg.passwordHasher.Verify(previous.HashedPassword.String, body.CurrentPassword)
+		if !success || err != nil {
+			cfg.recordLoginFailure(r, previous.Email)
+			respondWithError(w, http.StatusForbidden, "current password is incorrect", err)
+			return
+		}
+	} else if p.Credential != credentialSession {
+		respondInsufficientScope(w, "", "setting a first password requires a login session")
 		return
 	}
 
-	hpw, err := auth.HashPassword(body.Password)
+	hpw, err := cfg.passwordHasher.Hash(body.Password)
 	if err != nil {
 		respondWithError(w, http.StatusInternalServerError, "Unable to hash password", err)
 		return
@@ -147,65 +272,108 @@ func (cfg *apiConfig) handlerUpdateUser(w http.ResponseWriter, r *http.Request)
 	user, err := cfg.db.UpdateUser(context.Background(), database.UpdateUserParams{
 		ID:             userID,
 		Email:          body.Email,
-		HashedPassword: hpw,
+		HashedPassword: sql.NullString{String: hpw, Valid: true},
 	})
-	if err != nil {
-		log.Fatalf("unable to create user: %v", err)
+	if isUniqueViolation(err) {
+		respondWithError(w, http.StatusConflict, "email is already in use", err)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to update user", err)
+		return
+	}
+
+	if user.Email != previous.Email {
+		err = cfg.sendVerificationEmail(user)
+		if err != nil {
+			log.Printf("unable to send verification email to %s: %v", user.Email, err)
+		}
 	}
 
 	respondWithJSON(w, http.StatusOK, respBody{
-		Id:          user.ID,
-		CreatedAt:   user.CreatedAt,
-		UpdatedAt:   user.UpdatedAt,
-		Email:       user.Email,
-		IsChirpyRed: user.IsChirpyRed.Bool,
+		Id:            user.ID,
+		CreatedAt:     user.CreatedAt,
+		UpdatedAt:     user.UpdatedAt,
+		Email:         user.Email,
+		IsChirpyRed:   user.IsChirpyRed.Bool,
+		EmailVerified: user.EmailVerifiedAt.Valid,
 	})
 }
 
 func (cfg *apiConfig) handlerRefresh(w http.ResponseWriter, r *http.Request) {
 	type respBody struct {
-		Token string `json:"token"`
+		Token        string `json:"token"`
+		RefreshToken string `json:"refresh_token"`
 	}
-	authorization := r.Header.Get("Authorization")
-	if authorization == "" {
-		respondWithError(w, http.StatusUnauthorized, "no authorization header found", errors.New("no authorization header provided"))
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondUnauthorized(w, "", "no refresh token provided", err)
 		return
 	}
-	token := strings.Split(authorization, "Bearer ")[1]
 
-	refreshToken, err := cfg.db.GetRefreshToken(context.Background(), token)
+	refreshToken, err := cfg.getRefreshToken(context.Background(), token)
 	if err != nil {
-		respondWithError(w, http.StatusUnauthorized, "token not found", err)
+		respondUnauthorized(w, "invalid_token", "token not found", err)
+		return
+	} else if refreshToken.RevokedAt.Valid {
+		// A revoked token is only ever presented again if it was copied, so
+		// assume the whole family is compromised.
+		cfg.revokeTokenFamily(w, refreshToken.FamilyID)
 		return
 	} else if refreshToken.ExpiresAt.Before(time.Now()) {
 		respondWithError(w, http.StatusUnauthorized, "refresh token is expired", errors.New("refresh token is expired"))
 		return
-	} else if refreshToken.RevokedAt.Valid {
-		respondWithError(w, http.StatusUnauthorized, "refresh token is revoked", errors.New("refresh token is revoked"))
+	}
+
+	_, err = cfg.db.RotateRefreshToken(context.Background(), refreshToken.TokenHash)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Another request rotated this token since we read it.
+		cfg.revokeTokenFamily(w, refreshToken.FamilyID)
+		return
+	} else if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to rotate refresh token", err)
+		return
+	}
+
+	newRefreshToken, err := cfg.createRefreshToken(r, refreshToken.UserID, refreshToken.FamilyID, refreshToken.CreatedAt)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to store refresh token", err)
 		return
 	}
 
-	authToken, err := auth.MakeJWT(refreshToken.UserID, cfg.signingSecret, 1*time.Hour)
+	authToken, err := cfg.makeAccessToken(refreshToken.UserID, 1*time.Hour)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "unable to create auth token", err)
 		return
 	}
 
 	respondWithJSON(w, http.StatusOK, respBody{
-		Token: authToken,
+		Token:        authToken,
+		RefreshToken: newRefreshToken,
 	})
 }
 
-func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
-	authorization := r.Header.Get("Authorization")
-	if authorization == "" {
-		respondWithError(w, http.StatusUnauthorized, "no authorization header found", errors.New("no authorization header provided"))
+func (cfg *apiConfig) revokeTokenFamily(w http.ResponseWriter, familyID uuid.UUID) {
+	err := cfg.db.RevokeTokenFamily(context.Background(), familyID)
+	if err != nil {
+		respondWithError(w, http.StatusInternalServerError, "unable to revoke refresh token family", err)
 		return
 	}
+	respondWithError(w, http.StatusUnauthorized, "refresh token reuse detected", errors.New("refresh token reuse detected"))
+}
 
-	token := strings.Split(authorization, "Bearer ")[1]
+func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondUnauthorized(w, "", "no refresh token provided", err)
+		return
+	}
 
-	err := cfg.db.RevokeToken(context.Background(), token)
+	refreshToken, err := cfg.getRefreshToken(context.Background(), token)
+	if err != nil {
+		respondUnauthorized(w, "invalid_token", "token not found", err)
+		return
+	}
+	err = cfg.db.RevokeToken(context.Background(), refreshToken.TokenHash)
 	if err != nil {
 		respondWithError(w, http.StatusUnauthorized, "unable to revoke refresh token", err)
 		return
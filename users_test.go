@@ -0,0 +1,57 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/lib/pq"
+)
+
+func TestCreateUserWithTakenEmail(t *testing.T) {
+	cfg, mock, _ := newMockConfig(t)
+	mock.ExpectQuery("CreateUser").WillReturnError(&pq.Error{Code: "23505"})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/users", strings.NewReader(`{"email": "taken@example.com", "password": "hunter2"}`))
+	rec := httptest.NewRecorder()
+	cfg.handlerUsers(rec, req)
+	if rec.Code != http.StatusConflict {
+		t.Errorf("got %d: %s, want 409", rec.Code, rec.Body)
+	}
+}
+
+func TestUpdateUserErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		wantCode int
+	}{
+		{"email taken", &pq.Error{Code: "23505"}, http.StatusConflict},
+		{"db down", sql.ErrConnDone, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, mock, _ := newMockConfig(t)
+			user := unverifiedUser("user@example.com")
+			hash, err := cfg.passwordHasher.Hash("current")
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.HashedPassword = sql.NullString{String: hash, Valid: true}
+			mock.ExpectQuery("GetUserById").WithArgs(user.ID).WillReturnRows(userRow(user))
+			mock.ExpectQuery("GetLoginThrottles").WillReturnRows(sqlmock.NewRows(loginThrottleColumns))
+			mock.ExpectQuery("UpdateUser").WillReturnError(tt.err)
+
+			body := `{"email": "taken@example.com", "password": "new", "current_password": "current"}`
+			req := asUser(httptest.NewRequest(http.MethodPut, "/api/users", strings.NewReader(body)), user.ID)
+			rec := httptest.NewRecorder()
+			cfg.handlerUpdateUser(rec, req)
+			if rec.Code != tt.wantCode {
+				t.Errorf("got %d: %s, want %d", rec.Code, rec.Body, tt.wantCode)
+			}
+		})
+	}
+}